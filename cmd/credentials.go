@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Kafsh-e-Mardane-Varzeshi-Hypo-Test-Team/CT_HW1/internal/credentials"
+)
+
+const credentialsUsage = `usage:
+  credentials list
+  credentials set -host HOST [-user USER -password PASSWORD] [-token TOKEN] [-cookie COOKIE]
+  credentials remove -host HOST`
+
+func loadCredentials() (*credentials.Store, error) {
+	return credentials.Load(credentialsFile, credentialsKeyFile, netrcPath())
+}
+
+// netrcPath returns $NETRC, or ~/.netrc when it is not set.
+func netrcPath() string {
+	if path := os.Getenv("NETRC"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".netrc")
+}
+
+// runCredentials manages the credential store from the command line, so
+// secrets never have to be typed into the TUI.
+func runCredentials(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, credentialsUsage)
+		os.Exit(2)
+	}
+
+	flags := flag.NewFlagSet("credentials "+args[0], flag.ExitOnError)
+	var c credentials.Credential
+	flags.StringVar(&c.Host, "host", "", "host the credential is sent to")
+	flags.StringVar(&c.Username, "user", "", "basic auth user")
+	flags.StringVar(&c.Password, "password", "", "basic auth password")
+	flags.StringVar(&c.Token, "token", "", "bearer token")
+	flags.StringVar(&c.Cookie, "cookie", "", "cookies, as name=value; name2=value")
+	flags.Parse(args[1:])
+
+	store, err := loadCredentials()
+	if err == nil {
+		switch args[0] {
+		case "list":
+			for _, c := range store.List() {
+				fmt.Printf("%s\t%s\n", c.Host, c.Kind())
+			}
+		case "set":
+			err = store.Set(c)
+		case "remove":
+			err = store.Remove(c.Host)
+		default:
+			fmt.Fprintln(os.Stderr, credentialsUsage)
+			os.Exit(2)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -2,7 +2,10 @@ package main
 
 import (
 	"log"
+	"os"
 	"time"
+	// Queue time zones work even where the system has no zone database.
+	_ "time/tzdata"
 
 	tea "github.com/charmbracelet/bubbletea"
 
@@ -14,12 +17,34 @@ import (
 
 const filename string = "internal/persistence/data.json"
 
+const configFile string = "internal/persistence/config.json"
+
+const (
+	credentialsFile    string = "internal/persistence/credentials.enc"
+	credentialsKeyFile string = "internal/persistence/credentials.key"
+)
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "credentials" {
+		runCredentials(os.Args[2:])
+		return
+	}
+
 	go logger.StartLoggingToFile()
 	manager, err := persistence.Load(filename)
 	if err != nil {
 		log.Fatalln(err)
 	}
+	store, err := loadCredentials()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	manager.SetCredentials(store)
+	config, err := persistence.LoadConfig(configFile)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	manager.SetConfig(config)
 	saveState(manager)
 	manager.Start()
 
@@ -0,0 +1,67 @@
+package credentials
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// parseNetrc reads the machine and default entries of a .netrc file. The
+// default entry is returned with an empty Host.
+func parseNetrc(path string) ([]Credential, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var list []Credential
+	var current *Credential
+	var inMacro bool
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		// A macro definition runs until the next empty line.
+		if inMacro {
+			inMacro = strings.TrimSpace(line) != ""
+			continue
+		}
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		for i := 0; i < len(fields); i++ {
+			value := ""
+			if i+1 < len(fields) {
+				value = fields[i+1]
+			}
+			switch fields[i] {
+			case "machine":
+				list = append(list, Credential{Host: value})
+				current = &list[len(list)-1]
+				i++
+			case "default":
+				list = append(list, Credential{})
+				current = &list[len(list)-1]
+			case "login":
+				if current != nil {
+					current.Username = value
+				}
+				i++
+			case "password":
+				if current != nil {
+					current.Password = value
+				}
+				i++
+			case "account":
+				i++
+			case "macdef":
+				inMacro = true
+				i = len(fields)
+			}
+		}
+	}
+	return list, scanner.Err()
+}
@@ -0,0 +1,349 @@
+package credentials
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Credential authenticates the requests sent to a host. A credential may
+// combine an Authorization (basic or bearer) with cookies.
+type Credential struct {
+	Host     string
+	Username string
+	Password string
+	Token    string
+	Cookie   string
+	// Headers are the other headers carrying credentials, such as an
+	// Authorization of another scheme or an API key.
+	Headers http.Header
+}
+
+// Kind describes the credential without revealing it, e.g. "basic+cookie".
+func (c Credential) Kind() string {
+	var kinds []string
+	if c.Username != "" || c.Password != "" {
+		kinds = append(kinds, "basic")
+	}
+	if c.Token != "" {
+		kinds = append(kinds, "bearer")
+	}
+	if c.Cookie != "" {
+		kinds = append(kinds, "cookie")
+	}
+	if len(c.Headers) > 0 {
+		kinds = append(kinds, "custom")
+	}
+	return strings.Join(kinds, "+")
+}
+
+// Apply adds the credential to req, leaving headers the request already has.
+func (c Credential) Apply(req *http.Request) {
+	c.AddTo(req.Header)
+}
+
+// AddTo adds the credential to header, leaving the headers it already has.
+func (c Credential) AddTo(header http.Header) {
+	if header.Get("Authorization") == "" {
+		if c.Token != "" {
+			header.Set("Authorization", "Bearer "+c.Token)
+		} else if c.Username != "" || c.Password != "" {
+			header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(c.Username+":"+c.Password)))
+		}
+	}
+	if c.Cookie != "" && header.Get("Cookie") == "" {
+		header.Set("Cookie", c.Cookie)
+	}
+	for name, values := range c.Headers {
+		if header.Get(name) == "" {
+			header[name] = slices.Clone(values)
+		}
+	}
+}
+
+// hasAuthorization reports whether the credential sets an Authorization.
+func (c Credential) hasAuthorization() bool {
+	return c.Username != "" || c.Password != "" || c.Token != "" || c.Headers.Get("Authorization") != ""
+}
+
+// FromHeaders builds a credential for host out of the Authorization and
+// Cookie headers, and of the other headers isSecret says carry credentials.
+// ok is false when the headers hold nothing it can store. Authorization
+// schemes other than Basic and Bearer are kept as they are in Headers.
+func FromHeaders(host string, header http.Header, isSecret func(name string) bool) (c Credential, ok bool) {
+	c.Host = host
+	c.Headers = http.Header{}
+	if auth := header.Get("Authorization"); auth != "" {
+		scheme, value, _ := strings.Cut(auth, " ")
+		switch strings.ToLower(scheme) {
+		case "basic":
+			decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+			if err == nil {
+				c.Username, c.Password, _ = strings.Cut(string(decoded), ":")
+			} else {
+				c.Headers.Set("Authorization", auth)
+			}
+		case "bearer":
+			c.Token = strings.TrimSpace(value)
+		default:
+			c.Headers.Set("Authorization", auth)
+		}
+		ok = true
+	}
+	if cookie := header.Get("Cookie"); cookie != "" {
+		c.Cookie = cookie
+		ok = true
+	}
+	for name, values := range header {
+		if name != "Authorization" && name != "Cookie" && isSecret(name) {
+			c.Headers[name] = slices.Clone(values)
+			ok = true
+		}
+	}
+	if len(c.Headers) == 0 {
+		c.Headers = nil
+	}
+	return c, ok
+}
+
+// Store keeps credentials per host in a file encrypted with AES-GCM, under a
+// key kept in a separate file. Hosts it does not know are looked up in the
+// user's .netrc.
+type Store struct {
+	mu          sync.Mutex
+	path        string
+	key         []byte
+	credentials map[string]Credential
+	netrc       []Credential
+}
+
+// Load opens the store at path, creating the key file on first use. An
+// empty netrcPath skips the .netrc lookup.
+func Load(path, keyPath, netrcPath string) (*Store, error) {
+	key, err := loadKey(keyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Store{
+		path:        path,
+		key:         key,
+		credentials: make(map[string]Credential),
+	}
+	if err := s.read(); err != nil {
+		return nil, err
+	}
+
+	if netrcPath != "" {
+		s.netrc, err = parseNetrc(netrcPath)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("Error reading %s: %v\n", netrcPath, err)
+		}
+	}
+	return s, nil
+}
+
+func loadKey(keyPath string) ([]byte, error) {
+	data, err := os.ReadFile(keyPath)
+	if err == nil {
+		key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(key) != 32 {
+			return nil, errors.New("invalid credentials key in " + keyPath)
+		}
+		return key, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	err = os.WriteFile(keyPath, []byte(hex.EncodeToString(key)+"\n"), 0600)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Get returns the credential of host, falling back to .netrc.
+func (s *Store) Get(host string) (Credential, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if c, exists := s.credentials[host]; exists {
+		return c, true
+	}
+	var fallback *Credential
+	for i, c := range s.netrc {
+		if c.Host == host {
+			return c, true
+		}
+		if c.Host == "" && fallback == nil {
+			fallback = &s.netrc[i]
+		}
+	}
+	if fallback != nil {
+		c := *fallback
+		c.Host = host
+		return c, true
+	}
+	return Credential{}, false
+}
+
+// Stored returns the credential kept for host in the encrypted file, without
+// falling back to .netrc.
+func (s *Store) Stored(host string) (Credential, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, exists := s.credentials[host]
+	return c, exists
+}
+
+// List returns the credentials of the encrypted file, sorted by host.
+func (s *Store) List() []Credential {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var list []Credential
+	for _, host := range slices.Sorted(maps.Keys(s.credentials)) {
+		list = append(list, s.credentials[host])
+	}
+	return list
+}
+
+func (s *Store) Set(c Credential) error {
+	if c.Host == "" {
+		return errors.New("host cannot be empty")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.credentials[c.Host] = c
+	return s.write()
+}
+
+// Merge stores what c sets on top of the credential already kept for its
+// host. A new Authorization replaces the old one whatever its scheme, and
+// new cookies or headers replace those of the same name.
+func (s *Store) Merge(c Credential) error {
+	if c.Host == "" {
+		return errors.New("host cannot be empty")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	merged, exists := s.credentials[c.Host]
+	if !exists {
+		s.credentials[c.Host] = c
+		return s.write()
+	}
+	merged.Headers = merged.Headers.Clone()
+	if merged.Headers == nil {
+		merged.Headers = http.Header{}
+	}
+	if c.hasAuthorization() {
+		merged.Username, merged.Password, merged.Token = c.Username, c.Password, c.Token
+		merged.Headers.Del("Authorization")
+	}
+	if c.Cookie != "" {
+		merged.Cookie = c.Cookie
+	}
+	for name, values := range c.Headers {
+		merged.Headers[name] = slices.Clone(values)
+	}
+	if len(merged.Headers) == 0 {
+		merged.Headers = nil
+	}
+	s.credentials[c.Host] = merged
+	return s.write()
+}
+
+func (s *Store) Remove(host string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.credentials[host]; !exists {
+		return errors.New("no credentials for " + host)
+	}
+	delete(s.credentials, host)
+	return s.write()
+}
+
+func (s *Store) read() error {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	gcm, err := s.cipher()
+	if err != nil {
+		return err
+	}
+	if len(data) < gcm.NonceSize() {
+		return errors.New("credentials file is corrupted")
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return errors.New("credentials file does not match its key")
+	}
+
+	var list []Credential
+	if err := json.Unmarshal(plain, &list); err != nil {
+		return err
+	}
+	for _, c := range list {
+		s.credentials[c.Host] = c
+	}
+	return nil
+}
+
+// write saves the credentials. The caller must hold s.mu.
+func (s *Store) write() error {
+	plain, err := json.Marshal(slices.Collect(maps.Values(s.credentials)))
+	if err != nil {
+		return err
+	}
+
+	gcm, err := s.cipher()
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	tmp := s.path + ".tmp"
+	err = os.WriteFile(tmp, gcm.Seal(nonce, nonce, plain, nil), 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+func (s *Store) cipher() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(s.key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
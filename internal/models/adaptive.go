@@ -0,0 +1,72 @@
+package models
+
+import (
+	"log"
+	"time"
+)
+
+// ADAPTIVE_INTERVAL is how long the throughput is measured before deciding
+// whether another connection is worth opening.
+const ADAPTIVE_INTERVAL = 3 * time.Second
+
+// ADAPTIVE_MIN_GAIN is the relative throughput increase the last added
+// connection must bring for the download to keep adding connections.
+const ADAPTIVE_MIN_GAIN = 0.1
+
+// connectionTuner grows the number of running parts of a download one at a
+// time, for as long as each new connection raises the aggregate throughput.
+type connectionTuner struct {
+	lastSize int64
+	lastTime time.Time
+	bestRate float64
+	done     bool
+}
+
+func (d *Download) isAdaptive() bool {
+	return d.MaxPartCount > 0
+}
+
+// tuneConnections measures the throughput since the previous call and
+// returns a new part when another connection should be opened.
+func (d *Download) tuneConnections(t *connectionTuner) *Part {
+	var size int64
+	for _, part := range d.getParts() {
+		part.mu.Lock()
+		size += part.DownloadedBytes
+		part.mu.Unlock()
+	}
+
+	now := time.Now()
+	if t.lastTime.IsZero() {
+		t.lastSize, t.lastTime = size, now
+		return nil
+	}
+	rate := float64(size-t.lastSize) / now.Sub(t.lastTime).Seconds()
+	t.lastSize, t.lastTime = size, now
+
+	if t.done {
+		return nil
+	}
+	if t.bestRate > 0 && rate < t.bestRate*(1+ADAPTIVE_MIN_GAIN) {
+		log.Printf("downloadID = %d stops adding connections at %.2f MB/s\n", d.ID, rate/1000/1000)
+		t.done = true
+		return nil
+	}
+	t.bestRate = max(t.bestRate, rate)
+
+	running := 0
+	for _, part := range d.getParts() {
+		if part.getStatus() == InProgress {
+			running++
+		}
+	}
+	if running >= d.MaxPartCount {
+		return nil
+	}
+
+	part := d.stealWork()
+	if part != nil {
+		log.Printf("downloadID = %d opens connection %d at %.2f MB/s\n", d.ID, running+1, rate/1000/1000)
+	}
+	return part
+}
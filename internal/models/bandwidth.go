@@ -0,0 +1,273 @@
+package models
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+// READ_BUFFER_SIZE is the most a part reads from a connection at once.
+const READ_BUFFER_SIZE = 32 * 1024
+
+// BANDWIDTH_BURST is how long a limiter saves up unused bandwidth for, which
+// the parts may then receive at once.
+const BANDWIDTH_BURST = 250 * time.Millisecond
+
+// BandwidthLimiter is a token bucket holding one token per byte, refilled
+// at rate bytes per second. The parts sharing it take turns reserving the
+// bytes they read, so each gets a fair share of the rate.
+type BandwidthLimiter struct {
+	mu     sync.Mutex
+	rate   int64
+	tokens float64
+	last   time.Time
+	used   int64
+	stop   chan struct{}
+}
+
+// NewBandwidthLimiter returns a limiter of rate bytes per second, or one
+// that does not limit when rate is 0. Waits end early once stop is closed.
+func NewBandwidthLimiter(rate int64, stop chan struct{}) *BandwidthLimiter {
+	bl := &BandwidthLimiter{
+		rate: rate,
+		last: time.Now(),
+		stop: stop,
+	}
+	bl.tokens = bl.burst()
+	return bl
+}
+
+// WaitN blocks until n more bytes may be received. The bytes are reserved
+// before waiting, so a later caller waits behind the earlier ones.
+func (bl *BandwidthLimiter) WaitN(n int) {
+	delay := bl.reserve(n)
+	if delay <= 0 {
+		return
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-bl.stop:
+	}
+}
+
+// reserve takes n tokens, going into debt when there are not enough, and
+// returns how long it takes until the debt is paid off.
+func (bl *BandwidthLimiter) reserve(n int) time.Duration {
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+
+	if bl.rate <= 0 {
+		return 0
+	}
+
+	now := time.Now()
+	bl.tokens = min(bl.burst(), bl.tokens+now.Sub(bl.last).Seconds()*float64(bl.rate))
+	bl.last = now
+	bl.tokens -= float64(n)
+	bl.used += int64(n)
+	if bl.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-bl.tokens / float64(bl.rate) * float64(time.Second))
+}
+
+// burst returns how many tokens the bucket holds at most.
+func (bl *BandwidthLimiter) burst() float64 {
+	return max(float64(bl.rate)*BANDWIDTH_BURST.Seconds(), READ_BUFFER_SIZE)
+}
+
+// readSize returns how much a part should read at once. At low rates reads
+// are smaller, so the transfer is not a burst followed by a long wait.
+func (bl *BandwidthLimiter) readSize() int {
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+
+	if bl.rate <= 0 {
+		return READ_BUFFER_SIZE
+	}
+	return int(min(max(bl.rate/10, 1024), READ_BUFFER_SIZE))
+}
+
+// SetRate changes the rate of the limiter, 0 to stop limiting. Parts that
+// are waiting keep their reservation.
+func (bl *BandwidthLimiter) SetRate(rate int64) {
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+
+	now := time.Now()
+	if bl.rate > 0 {
+		bl.tokens += now.Sub(bl.last).Seconds() * float64(bl.rate)
+	}
+	bl.last = now
+	bl.rate = rate
+	bl.tokens = min(bl.burst(), bl.tokens)
+}
+
+// takeUsage returns the bytes reserved since it was last called.
+func (bl *BandwidthLimiter) takeUsage() int64 {
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+
+	used := bl.used
+	bl.used = 0
+	return used
+}
+
+// BANDWIDTH_REBALANCE_INTERVAL is how often the global speed limit is split
+// again between the queues, according to what each used.
+const BANDWIDTH_REBALANCE_INTERVAL = time.Second
+
+// bandwidthAllocator splits the global speed limit between the limiters of
+// the active queues. A queue that used less than its share keeps what it
+// used, and the rest is split evenly between the others.
+type bandwidthAllocator struct {
+	mu       sync.Mutex
+	max      int64
+	limiters map[*BandwidthLimiter]*allocation
+	running  bool
+}
+
+type allocation struct {
+	// limit is the queue's own speed limit, 0 for none.
+	limit int64
+	rate  int64
+	// saturated is set when the queue used nearly all of its rate, so it
+	// would take more.
+	saturated bool
+}
+
+func newBandwidthAllocator() *bandwidthAllocator {
+	return &bandwidthAllocator{
+		limiters: make(map[*BandwidthLimiter]*allocation),
+	}
+}
+
+// setMax changes the global speed limit, 0 for none.
+func (a *bandwidthAllocator) setMax(max int64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.max = max
+	a.rebalance()
+}
+
+// register adds the limiter of a queue that started, whose own speed limit
+// is limit.
+func (a *bandwidthAllocator) register(bl *BandwidthLimiter, limit int64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.limiters[bl] = &allocation{limit: limit, saturated: true}
+	a.rebalance()
+	if !a.running {
+		a.running = true
+		go a.run()
+	}
+}
+
+// setLimit changes the own speed limit of a registered queue.
+func (a *bandwidthAllocator) setLimit(bl *BandwidthLimiter, limit int64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	alloc, exists := a.limiters[bl]
+	if !exists || alloc.limit == limit {
+		return
+	}
+	alloc.limit = limit
+	alloc.saturated = true
+	a.rebalance()
+}
+
+// unregister removes the limiter of a queue that stopped.
+func (a *bandwidthAllocator) unregister(bl *BandwidthLimiter) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	delete(a.limiters, bl)
+	a.rebalance()
+}
+
+func (a *bandwidthAllocator) run() {
+	ticker := time.NewTicker(BANDWIDTH_REBALANCE_INTERVAL)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		a.mu.Lock()
+		if len(a.limiters) == 0 {
+			a.running = false
+			a.mu.Unlock()
+			return
+		}
+		for bl, alloc := range a.limiters {
+			used := float64(bl.takeUsage()) / BANDWIDTH_REBALANCE_INTERVAL.Seconds()
+			alloc.saturated = alloc.rate == 0 || used >= 0.8*float64(alloc.rate)
+			if !alloc.saturated {
+				// What a queue uses is all it asks for until it runs into it.
+				alloc.rate = int64(used)
+			}
+		}
+		a.rebalance()
+		a.mu.Unlock()
+	}
+}
+
+// rebalance shares the global limit by water-filling: the queues asking for
+// the least are served first, each with at most an even share of what is
+// left. Without a global limit every queue runs at its own.
+func (a *bandwidthAllocator) rebalance() {
+	if a.max <= 0 {
+		for bl, alloc := range a.limiters {
+			alloc.rate = alloc.limit
+			bl.SetRate(alloc.rate)
+		}
+		return
+	}
+
+	// demand is what a queue asks for, -1 for as much as it gets.
+	demand := func(alloc *allocation) int64 {
+		if alloc.saturated {
+			if alloc.limit > 0 {
+				return alloc.limit
+			}
+			return -1
+		}
+		return alloc.rate
+	}
+	limiters := slices.Collect(maps.Keys(a.limiters))
+	slices.SortFunc(limiters, func(x, y *BandwidthLimiter) int {
+		dx, dy := demand(a.limiters[x]), demand(a.limiters[y])
+		if dx < 0 || dy < 0 {
+			return cmp.Compare(-dx, -dy)
+		}
+		return cmp.Compare(dx, dy)
+	})
+
+	// A queue that is idle now still gets a little, so it can show that it
+	// wants more.
+	floor := max(a.max/100, 1024)
+	remaining := a.max
+	for i, bl := range limiters {
+		alloc := a.limiters[bl]
+		share := remaining / int64(len(limiters)-i)
+		if d := demand(alloc); d >= 0 {
+			share = min(share, d)
+		}
+		alloc.rate = max(share, floor)
+		remaining = max(0, remaining-alloc.rate)
+	}
+	// Whatever nobody asked for is spread evenly, so the queues can grow.
+	for _, bl := range limiters {
+		alloc := a.limiters[bl]
+		alloc.rate += remaining / int64(len(limiters))
+		if alloc.limit > 0 {
+			alloc.rate = min(alloc.rate, alloc.limit)
+		}
+		bl.SetRate(alloc.rate)
+	}
+}
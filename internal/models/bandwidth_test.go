@@ -0,0 +1,261 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestBandwidthLimiterBurst(t *testing.T) {
+	tests := []struct {
+		rate int64
+		want float64
+	}{
+		{0, READ_BUFFER_SIZE},
+		{10 * 1024, READ_BUFFER_SIZE},
+		{1024 * 1024, 256 * 1024},
+		{8 * 1024 * 1024, 2 * 1024 * 1024},
+	}
+	for _, test := range tests {
+		bl := NewBandwidthLimiter(test.rate, nil)
+		if got := bl.burst(); got != test.want {
+			t.Errorf("burst at %d B/s = %v, want %v", test.rate, got, test.want)
+		}
+	}
+}
+
+func TestBandwidthLimiterReadSize(t *testing.T) {
+	tests := []struct {
+		rate int64
+		want int
+	}{
+		{0, READ_BUFFER_SIZE},
+		{1024, 1024},
+		{100 * 1024, 10 * 1024},
+		{10 * 1024 * 1024, READ_BUFFER_SIZE},
+	}
+	for _, test := range tests {
+		bl := NewBandwidthLimiter(test.rate, nil)
+		if got := bl.readSize(); got != test.want {
+			t.Errorf("read size at %d B/s = %d, want %d", test.rate, got, test.want)
+		}
+	}
+}
+
+// assertDelay checks that a reservation waits for want, give or take the
+// time the test itself takes.
+func assertDelay(t *testing.T, what string, got, want time.Duration) {
+	t.Helper()
+	if math.Abs(float64(got-want)) > float64(5*time.Millisecond) {
+		t.Errorf("%s waits %v, want %v", what, got, want)
+	}
+}
+
+func TestBandwidthLimiterReserve(t *testing.T) {
+	const rate = 1024 * 1024
+	bl := NewBandwidthLimiter(rate, nil)
+
+	// The bucket starts full, so the first burst does not wait.
+	assertDelay(t, "the burst", bl.reserve(int(bl.burst())), 0)
+	// Then every reservation waits for the ones before it.
+	assertDelay(t, "the first reservation", bl.reserve(rate/10), 100*time.Millisecond)
+	assertDelay(t, "the second reservation", bl.reserve(rate/10), 200*time.Millisecond)
+	if used := bl.takeUsage(); used != int64(bl.burst())+2*(rate/10) {
+		t.Errorf("usage = %d, want %d", used, int64(bl.burst())+2*(rate/10))
+	}
+	if used := bl.takeUsage(); used != 0 {
+		t.Errorf("usage after taking it = %d, want 0", used)
+	}
+}
+
+func TestBandwidthLimiterUnlimited(t *testing.T) {
+	bl := NewBandwidthLimiter(0, nil)
+	for range 10 {
+		assertDelay(t, "an unlimited reservation", bl.reserve(1024*1024), 0)
+	}
+}
+
+func TestBandwidthLimiterSetRate(t *testing.T) {
+	const rate = 1024 * 1024
+	bl := NewBandwidthLimiter(rate, nil)
+	bl.reserve(int(bl.burst()) + rate/10)
+
+	// The debt of 100ms at the old rate takes 200ms at half of it.
+	bl.SetRate(rate / 2)
+	assertDelay(t, "a reservation after halving the rate", bl.reserve(0), 200*time.Millisecond)
+
+	// Without a limit nothing waits, and a new limit never starts with more
+	// than its burst.
+	bl.SetRate(0)
+	assertDelay(t, "an unlimited reservation", bl.reserve(rate), 0)
+	bl.SetRate(rate)
+	if bl.tokens > bl.burst() {
+		t.Errorf("bucket holds %v tokens, more than its burst of %v", bl.tokens, bl.burst())
+	}
+}
+
+func TestBandwidthLimiterStop(t *testing.T) {
+	stop := make(chan struct{})
+	bl := NewBandwidthLimiter(1024, stop)
+	bl.reserve(int(bl.burst()))
+	close(stop)
+
+	start := time.Now()
+	bl.WaitN(1024 * 1024)
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("WaitN took %v after stop was closed", elapsed)
+	}
+}
+
+// allocate rebalances limit between limiters with the given allocations and
+// returns the rate each gets.
+func allocate(max int64, allocations []allocation) []int64 {
+	a := newBandwidthAllocator()
+	a.max = max
+	limiters := make([]*BandwidthLimiter, len(allocations))
+	for i := range allocations {
+		limiters[i] = NewBandwidthLimiter(0, nil)
+		a.limiters[limiters[i]] = &allocations[i]
+	}
+	a.rebalance()
+
+	rates := make([]int64, len(limiters))
+	for i, bl := range limiters {
+		rates[i] = bl.rate
+	}
+	return rates
+}
+
+func TestBandwidthAllocatorRebalance(t *testing.T) {
+	const kb = 1024
+	tests := []struct {
+		name        string
+		max         int64
+		allocations []allocation
+		want        []int64
+	}{
+		{
+			name:        "no global limit",
+			max:         0,
+			allocations: []allocation{{limit: 100 * kb, saturated: true}, {limit: 0, saturated: true}},
+			want:        []int64{100 * kb, 0},
+		},
+		{
+			name:        "even split",
+			max:         900 * kb,
+			allocations: []allocation{{saturated: true}, {saturated: true}, {saturated: true}},
+			want:        []int64{300 * kb, 300 * kb, 300 * kb},
+		},
+		{
+			name:        "own limit below the share",
+			max:         900 * kb,
+			allocations: []allocation{{limit: 100 * kb, saturated: true}, {saturated: true}, {saturated: true}},
+			want:        []int64{100 * kb, 400 * kb, 400 * kb},
+		},
+		{
+			name:        "share of a queue using less goes to the others",
+			max:         900 * kb,
+			allocations: []allocation{{rate: 50 * kb}, {saturated: true}, {saturated: true}},
+			want:        []int64{50 * kb, 425 * kb, 425 * kb},
+		},
+		{
+			name:        "idle queue keeps the floor",
+			max:         900 * kb,
+			allocations: []allocation{{rate: 0}, {saturated: true}},
+			want:        []int64{9 * kb, 891 * kb},
+		},
+		{
+			name:        "leftover is spread within own limits",
+			max:         900 * kb,
+			allocations: []allocation{{limit: 100 * kb, saturated: true}, {limit: 200 * kb, saturated: true}},
+			want:        []int64{100 * kb, 200 * kb},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := allocate(test.max, test.allocations)
+			var total int64
+			for i := range got {
+				total += got[i]
+				if got[i] != test.want[i] {
+					t.Errorf("queue %d gets %d B/s, want %d", i, got[i], test.want[i])
+				}
+			}
+			if test.max > 0 && total > test.max {
+				t.Errorf("queues get %d B/s together, over the global %d", total, test.max)
+			}
+		})
+	}
+}
+
+// partBytes returns how many bytes each part of d has received.
+func partBytes(d *Download) []int64 {
+	var received []int64
+	for _, part := range d.getParts() {
+		received = append(received, part.getDownloadedBytes())
+	}
+	return received
+}
+
+func TestBandwidthLimiterDownload(t *testing.T) {
+	const duration = 3 * time.Second
+	tests := []struct {
+		rate  int64
+		parts int
+	}{
+		{256 * 1024, 1},
+		{256 * 1024, 4},
+		{2 * 1024 * 1024, 1},
+		{2 * 1024 * 1024, 4},
+	}
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%dKBps/%dparts", test.rate/1024, test.parts), func(t *testing.T) {
+			t.Parallel()
+			data := testData(int(test.rate * int64(duration/time.Second)))
+			server := newFileServer(data)
+			defer server.Close()
+
+			d := NewDownload(0, server.URL+"/file.bin", t.TempDir(), "file.bin", "queue")
+			d.PartCount = test.parts
+			d.MinPartSize = 1
+			stop := make(chan struct{})
+			defer close(stop)
+			bl := NewBandwidthLimiter(test.rate, stop)
+
+			start := time.Now()
+			done := make(chan error, 1)
+			go func() { done <- d.Start(bl) }()
+
+			// Whichever part connects first may take the burst, but from then
+			// on the parts take turns, so none gets more than a read ahead.
+			time.Sleep(duration / 12)
+			before := partBytes(d)
+			time.Sleep(duration / 3)
+			after := partBytes(d)
+			if len(before) != test.parts || len(after) != test.parts {
+				t.Fatalf("download has %d parts, then %d, want %d", len(before), len(after), test.parts)
+			}
+			lowest, highest := int64(math.MaxInt64), int64(0)
+			for i := range after {
+				lowest = min(lowest, after[i]-before[i])
+				highest = max(highest, after[i]-before[i])
+			}
+			if highest-lowest > int64(bl.readSize())+lowest/10 {
+				t.Errorf("parts received between %d and %d bytes, not an even share", lowest, highest)
+			}
+
+			if err := <-done; err != nil {
+				t.Fatal(err)
+			}
+			elapsed := time.Since(start)
+			checkFile(t, d.Path, data)
+
+			// The bucket starts full, so its burst comes for free.
+			want := time.Duration((float64(len(data)) - bl.burst()) / float64(test.rate) * float64(time.Second))
+			if math.Abs(float64(elapsed-want)) > 0.05*float64(want) {
+				t.Errorf("download took %v, want %v within 5%%", elapsed, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+var errChecksumMismatch = errors.New("checksum mismatch")
+
+// parseChecksum accepts either "algorithm:hexdigest" or a bare hex digest,
+// in which case the algorithm is guessed from the digest length.
+func parseChecksum(checksum string) (string, []byte, error) {
+	algorithm, digest, found := strings.Cut(strings.TrimSpace(checksum), ":")
+	if !found {
+		digest = algorithm
+		switch len(digest) {
+		case 64:
+			algorithm = "sha256"
+		case 40:
+			algorithm = "sha1"
+		case 32:
+			algorithm = "md5"
+		default:
+			return "", nil, errors.New("cannot guess checksum algorithm from digest length")
+		}
+	}
+	algorithm = strings.ToLower(strings.ReplaceAll(algorithm, "-", ""))
+
+	h, err := newHash(algorithm)
+	if err != nil {
+		return "", nil, err
+	}
+
+	sum, err := hex.DecodeString(digest)
+	if err != nil {
+		return "", nil, errors.New("checksum is not a valid hex string")
+	}
+	if len(sum) != h.Size() {
+		return "", nil, fmt.Errorf("%s checksum must be %d hex characters", algorithm, 2*h.Size())
+	}
+	return algorithm, sum, nil
+}
+
+func newHash(algorithm string) (hash.Hash, error) {
+	switch algorithm {
+	case "sha256":
+		return sha256.New(), nil
+	case "sha1":
+		return sha1.New(), nil
+	case "md5":
+		return md5.New(), nil
+	}
+	return nil, fmt.Errorf("unsupported checksum algorithm %q", algorithm)
+}
+
+func (d *Download) verifyChecksum() error {
+	if d.Checksum == "" {
+		return nil
+	}
+
+	algorithm, expected, err := parseChecksum(d.Checksum)
+	if err != nil {
+		return err
+	}
+	h, _ := newHash(algorithm)
+
+	file, err := os.Open(d.Path)
+	if err != nil {
+		log.Printf("Error opening merged file for checksum of downloadID = %d: %v\n", d.ID, err)
+		return err
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(h, file); err != nil {
+		log.Printf("Error hashing merged file of downloadID = %d: %v\n", d.ID, err)
+		return err
+	}
+
+	actual := h.Sum(nil)
+	if !bytes.Equal(actual, expected) {
+		return fmt.Errorf("%s %w: expected %x, got %x", algorithm, errChecksumMismatch, expected, actual)
+	}
+	log.Printf("%s checksum of downloadID = %d verified\n", algorithm, d.ID)
+	return nil
+}
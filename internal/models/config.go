@@ -0,0 +1,130 @@
+package models
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Config holds the settings read from the config file, as opposed to the
+// state saved with the manager.
+type Config struct {
+	Transport TransportConfig
+	// StallTimeout is how long a part may receive nothing before it drops
+	// the connection and reconnects where it left off, 0 to wait forever.
+	StallTimeout Duration
+	// MaxRetryAfter is the longest Retry-After of a server that is waited
+	// for. A request asked to wait longer fails instead, 0 for no limit.
+	MaxRetryAfter Duration
+	// Connections caps the connections the parts of all queues open at once.
+	Connections ConnectionLimits
+}
+
+// TransportConfig tunes the HTTP transport shared by all downloads.
+type TransportConfig struct {
+	// DialTimeout bounds establishing a TCP connection.
+	DialTimeout Duration
+	// KeepAlive is the interval of TCP keep-alive probes.
+	KeepAlive Duration
+	// TLSHandshakeTimeout bounds the TLS handshake.
+	TLSHandshakeTimeout Duration
+	// ResponseHeaderTimeout bounds the wait for the response headers once
+	// the request is sent. It does not limit reading the body.
+	ResponseHeaderTimeout Duration
+	// IdleConnTimeout is how long an unused connection stays in the pool.
+	IdleConnTimeout Duration
+	// MaxIdleConns caps the pooled connections, 0 for no limit.
+	MaxIdleConns int
+	// MaxIdleConnsPerHost caps the pooled connections to a single host.
+	MaxIdleConnsPerHost int
+	// MaxConnsPerHost caps all connections to a single host, 0 for no limit.
+	MaxConnsPerHost int
+	// HTTP2 negotiates HTTP/2 with servers that support it.
+	HTTP2 bool
+}
+
+// ConnectionLimits caps the connections of all downloads together, for
+// servers that ban clients which open too many.
+type ConnectionLimits struct {
+	// Max caps the connections to all hosts, 0 for no limit.
+	Max int
+	// PerHost caps the connections to a single host, 0 for no limit.
+	PerHost int
+	// Hosts overrides PerHost for the hosts it names.
+	Hosts map[string]int
+}
+
+// forHost returns the connection limit of host, 0 for no limit.
+func (c ConnectionLimits) forHost(host string) int {
+	if limit, exists := c.Hosts[strings.ToLower(host)]; exists {
+		return limit
+	}
+	return c.PerHost
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Transport: TransportConfig{
+			DialTimeout:           Duration(30 * time.Second),
+			KeepAlive:             Duration(30 * time.Second),
+			TLSHandshakeTimeout:   Duration(10 * time.Second),
+			ResponseHeaderTimeout: Duration(30 * time.Second),
+			IdleConnTimeout:       Duration(90 * time.Second),
+			MaxIdleConns:          100,
+			MaxIdleConnsPerHost:   16,
+			MaxConnsPerHost:       0,
+			HTTP2:                 true,
+		},
+		StallTimeout:  Duration(30 * time.Second),
+		MaxRetryAfter: Duration(10 * time.Minute),
+		Connections: ConnectionLimits{
+			Max:     0,
+			PerHost: 0,
+			Hosts:   map[string]int{},
+		},
+	}
+}
+
+// newTransport builds the transport described by c, taking its proxy from
+// proxy.
+func (c TransportConfig) newTransport(proxy func(*http.Request) (*url.URL, error)) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   time.Duration(c.DialTimeout),
+		KeepAlive: time.Duration(c.KeepAlive),
+	}
+	return &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   time.Duration(c.TLSHandshakeTimeout),
+		ResponseHeaderTimeout: time.Duration(c.ResponseHeaderTimeout),
+		IdleConnTimeout:       time.Duration(c.IdleConnTimeout),
+		MaxIdleConns:          c.MaxIdleConns,
+		MaxIdleConnsPerHost:   c.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       c.MaxConnsPerHost,
+		ForceAttemptHTTP2:     c.HTTP2,
+		ExpectContinueTimeout: time.Second,
+	}
+}
+
+// Duration is a time.Duration written as a string such as "30s" in JSON.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
@@ -1,18 +1,26 @@
 package models
 
 import (
+	"cmp"
 	"errors"
 	"io"
 	"log"
 	"net/http"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
 
 const NUMBER_OF_PARTS int = 5
 
+// MIN_PART_SIZE is the default smallest range a file is split into, both
+// when the parts are initialized and when a part hands half of its
+// remaining bytes over to a part that finished early.
+const MIN_PART_SIZE int64 = 1024 * 1024
+
 type Status int
 
 const (
@@ -27,21 +35,42 @@ const (
 type Download struct {
 	ID                 int
 	URL                string
+	FinalURL           string
 	Destination        string
 	OutputFileName     string
+	NameFromServer     bool
 	Path               string
 	QueueName          string
+	Headers            http.Header
 	headResp           *http.Response
 	NumberOfParts      int
-	TotalSize          int64
+	PartCount          int
+	MaxPartCount       int
+	MinPartSize        int64
+	TotalSize          int64 // -1 while the size of the file is unknown
 	lastDownloadedSize int64
 	DownloadedSize     int64
 	DownloadPercentage float64
 	currentSpeed       float64
 	lastUpdateTime     time.Time
 	channel            chan connectionWithPart
-	Parts              []Part
+	stop               chan Status
+	Parts              []*Part
 	IsInitialized      bool
+	Checksum           string
+	FailureReason      string
+	Preallocate        bool
+	ETag               string
+	LastModified       string
+	RangesUnsupported  bool
+	SpeedLimit         int64
+	NotBefore          time.Time
+	Deadline           time.Time
+	limiter            *BandwidthLimiter
+	file               *os.File
+	session            *session
+	proxy              string
+	retries            int
 	mu                 sync.Mutex
 	Status
 }
@@ -67,34 +96,106 @@ func NewDownload(id int, url, destination, outputFileName, queueName string) *Do
 }
 
 func (d *Download) setHttpResponse() error {
-	req, err := http.NewRequest("HEAD", d.URL, nil)
+	req, err := d.newRequest("HEAD", d.URL)
 	if err != nil {
 		log.Printf("Error in getting HEAD of http request for downloadID = %d %v\n", d.ID, err)
 		return err
 	}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	// The HEAD counts against the connection limits like the parts do.
+	d.mu.Lock()
+	stop := d.stop
+	d.mu.Unlock()
+	hosts, host := d.hostLimiter(), req.URL.Hostname()
+	if _, ok := hosts.acquire(host, stop); !ok {
+		return errStopped
+	}
+	defer hosts.release(host)
+
+	resp, err := d.httpClient().Do(req)
 	if err != nil {
 		log.Printf("Error performing http request for downloadID = %d: %v\n", d.ID, err)
 		return err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("Error getting response from server for downloadID = %d: %v\n", d.ID, err)
-		return errors.New("response status code is not OK")
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusForbidden, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		log.Printf("Server refused HEAD for downloadID = %d with %q, probing with a ranged GET\n", d.ID, resp.Status)
+		resp, err = d.probeWithRangedGet()
+		if err != nil {
+			return err
+		}
+	default:
+		log.Printf("Error getting response from server for downloadID = %d: %q\n", d.ID, resp.Status)
+		return newStatusError(resp)
 	}
 
 	d.headResp = resp
 	return nil
 }
 
+// probeWithRangedGet asks for the first byte of the file, for servers that
+// refuse HEAD. The response is rewritten to look like the answer to a HEAD:
+// the size comes from Content-Range and range support from the status.
+func (d *Download) probeWithRangedGet() (*http.Response, error) {
+	req, err := d.newRequest("GET", d.URL)
+	if err != nil {
+		log.Printf("Error in GET http request for downloadID = %d: %v\n", d.ID, err)
+		return nil, err
+	}
+	req.Header.Set("Range", "bytes=0-0")
+
+	resp, err := d.httpClient().Do(req)
+	if err != nil {
+		log.Printf("Error performing http request for downloadID = %d: %v\n", d.ID, err)
+		return nil, err
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		resp.ContentLength = parseContentRangeSize(resp.Header.Get("Content-Range"))
+		resp.Header.Set("Accept-Ranges", "bytes")
+	case http.StatusRequestedRangeNotSatisfiable:
+		// Only an empty file has no first byte.
+		resp.ContentLength = parseContentRangeSize(resp.Header.Get("Content-Range"))
+		resp.Header.Set("Accept-Ranges", "none")
+	case http.StatusOK:
+		resp.Header.Set("Accept-Ranges", "none")
+	default:
+		log.Printf("Error getting response from server for downloadID = %d: %q\n", d.ID, resp.Status)
+		return nil, newStatusError(resp)
+	}
+	return resp, nil
+}
+
+// parseContentRangeSize returns the complete length from a Content-Range
+// header such as "bytes 0-0/1234", or -1 when it is unknown.
+func parseContentRangeSize(contentRange string) int64 {
+	_, size, found := strings.Cut(contentRange, "/")
+	if !found {
+		return -1
+	}
+	n, err := strconv.ParseInt(size, 10, 64)
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
 func (d *Download) setTotalSize() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 	d.TotalSize = d.headResp.ContentLength
 }
 
 func (d *Download) supportsPartialDownload() bool {
+	if d.RangesUnsupported {
+		log.Printf("downloadID = %d ignored range requests before\n", d.ID)
+		return false
+	}
 	if d.headResp.Header.Get("Accept-Ranges") == "" || d.headResp.Header.Get("Accept-Ranges") == "none" {
 		log.Printf("downloadID = %d does not support partial downloading\n", d.ID)
 		return false
@@ -105,19 +206,132 @@ func (d *Download) supportsPartialDownload() bool {
 
 func (d *Download) downloadParts(bandwidthLimiter *BandwidthLimiter) error {
 	for i := range d.Parts {
+		d.Parts[i].file = d.file
 		go d.Parts[i].start(d.channel, bandwidthLimiter)
 	}
 
-	for range d.NumberOfParts {
-		result := <-d.channel
-		if result.error != nil {
-			if result.Status == Failed {
-				d.setStatus(Failed)
+	// Wait for every part to report, so none of them is still writing once
+	// this returns.
+	var err error
+	running := len(d.Parts)
+
+	var tuner connectionTuner
+	var tick <-chan time.Time
+	if d.isAdaptive() {
+		ticker := time.NewTicker(ADAPTIVE_INTERVAL)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for running > 0 {
+		select {
+		case result := <-d.channel:
+			running--
+			if result.error != nil && err == nil {
+				err = result.error
+				if result.Status == Failed {
+					d.setStatus(Failed)
+					d.stopParts(Failed)
+				}
+			}
+
+			if result.Status == Completed && err == nil && d.GetStatus() == InProgress {
+				part := d.stealWork()
+				if part != nil {
+					go part.start(d.channel, bandwidthLimiter)
+					running++
+				}
+			}
+		case <-tick:
+			if err != nil || d.GetStatus() != InProgress {
+				continue
+			}
+			part := d.tuneConnections(&tuner)
+			if part != nil {
+				go part.start(d.channel, bandwidthLimiter)
+				running++
+			}
+		}
+	}
+	return err
+}
+
+// stealWork splits the back half of the remaining range of the slowest
+// running part into a new part, so a connection that finished early keeps
+// working instead of waiting for the slow one.
+func (d *Download) stealWork() *Part {
+	var victim *Part
+	var victimEta float64
+	for _, part := range d.Parts {
+		if part.getStatus() != InProgress || part.remaining() < 2*d.getMinPartSize() {
+			continue
+		}
+		eta := part.eta()
+		if victim == nil || eta > victimEta || (eta == victimEta && part.remaining() > victim.remaining()) {
+			victim = part
+			victimEta = eta
+		}
+	}
+	if victim == nil {
+		return nil
+	}
+
+	req, err := d.newPartRequest()
+	if err != nil {
+		log.Printf("Error in GET http request for downloadID = %d: %v\n", d.ID, err)
+		return nil
+	}
+
+	start, end, ok := victim.split(d.getMinPartSize())
+	if !ok {
+		return nil
+	}
+
+	limiter := d.speedLimiter()
+	d.mu.Lock()
+	part := &Part{
+		PartIndex:       len(d.Parts),
+		StartIndex:      start,
+		EndIndex:        end,
+		DownloadedBytes: 0,
+		Status:          Pending,
+		req:             req,
+		client:          d.httpClient(),
+		stallTimeout:    d.stallTimeout(),
+		maxRetryAfter:   d.maxRetryAfter(),
+		retries:         d.retries,
+		hosts:           d.hostLimiter(),
+		limiter:         limiter,
+		file:            d.file,
+		channel:         make(chan Status, 1),
+	}
+	part.RangeOfDownload = part.formatRange()
+	if !d.Preallocate {
+		part.Path = d.Destination + "/" + d.OutputFileName + part.RangeOfDownload + ".part"
+	}
+	d.Parts = append(d.Parts, part)
+	d.NumberOfParts = len(d.Parts)
+	// The download may have been paused while splitting; the new part then
+	// waits for the next Start like the others.
+	inProgress := d.Status == InProgress
+	d.mu.Unlock()
+
+	log.Printf("partId = %d of downloadID = %d took over bytes %d - %d from partId = %d\n", part.PartIndex, d.ID, start, end, victim.PartIndex)
+	if !inProgress {
+		return nil
+	}
+	return part
+}
+
+func (d *Download) stopParts(status Status) {
+	for _, part := range d.Parts {
+		if part.getStatus() == InProgress {
+			select {
+			case part.channel <- status:
+			default:
 			}
-			return result.error
 		}
 	}
-	return nil
 }
 
 func (d *Download) mergeParts() error {
@@ -128,8 +342,11 @@ func (d *Download) mergeParts() error {
 	}
 	defer file.Close()
 
-	for i := range d.Parts {
-		part := &d.Parts[i]
+	parts := slices.Clone(d.Parts)
+	slices.SortFunc(parts, func(a, b *Part) int {
+		return cmp.Compare(a.StartIndex, b.StartIndex)
+	})
+	for _, part := range parts {
 		resp, err := os.Open(part.Path)
 		if err != nil {
 			log.Printf("Error opening part file while merging for partId = %d: %v\n", part.PartIndex, err)
@@ -153,56 +370,138 @@ func (d *Download) mergeParts() error {
 func (d *Download) initializeParts() error {
 	partSize := d.TotalSize / int64(d.NumberOfParts)
 	for i := range d.NumberOfParts {
-		d.Parts[i] = Part{
+		d.Parts[i] = &Part{
 			PartIndex:       i,
 			StartIndex:      int64(i) * partSize,
-			EndIndex:        int64(i+1) * partSize - 1,
+			EndIndex:        int64(i+1)*partSize - 1,
 			DownloadedBytes: 0,
 			Status:          Pending,
 		}
 
 		if i == d.NumberOfParts-1 {
-			d.Parts[i].EndIndex = d.TotalSize - 1
+			d.Parts[i].EndIndex = max(d.TotalSize-1, -1)
+		}
+		d.Parts[i].RangeOfDownload = d.Parts[i].formatRange()
+		if !d.Preallocate {
+			d.Parts[i].Path = d.Destination + "/" + d.OutputFileName + d.Parts[i].RangeOfDownload + ".part"
 		}
-		d.Parts[i].RangeOfDownload = strconv.Itoa(int(d.Parts[i].StartIndex+d.Parts[i].DownloadedBytes)) + "-" + strconv.Itoa(int(d.Parts[i].EndIndex))
-		d.Parts[i].Path = d.Destination + "/" + d.OutputFileName + d.Parts[i].RangeOfDownload + ".part"
 	}
 	return nil
 }
 
+func (d *Download) newPartRequest() (*http.Request, error) {
+	req, err := d.newRequest("GET", d.requestURL())
+	if err != nil {
+		return nil, err
+	}
+
+	// With If-Range the server only honors the range while the file is
+	// unchanged, and sends the whole new file otherwise.
+	if validator := d.ifRangeValidator(); validator != "" {
+		req.Header.Set("If-Range", validator)
+	}
+	return req, nil
+}
+
+// ifRangeValidator returns the ETag when it is a strong one, since If-Range
+// does not accept weak ETags, and the Last-Modified date otherwise.
+func (d *Download) ifRangeValidator() string {
+	if d.ETag != "" && !strings.HasPrefix(d.ETag, "W/") {
+		return d.ETag
+	}
+	return d.LastModified
+}
+
 func (d *Download) initializeRequestOfParts() error {
 	for i := range d.NumberOfParts {
-		req, err := http.NewRequest("GET", d.URL, nil)
+		req, err := d.newPartRequest()
 		if err != nil {
 			log.Printf("Error in GET http request for downloadID = %d: %v\n", d.ID, err)
 			return err
 		}
 		d.Parts[i].req = req
+		d.Parts[i].client = d.httpClient()
+		d.Parts[i].stallTimeout = d.stallTimeout()
+		d.Parts[i].maxRetryAfter = d.maxRetryAfter()
+		d.Parts[i].retries = d.retries
+		d.Parts[i].hosts = d.hostLimiter()
+		d.Parts[i].limiter = d.speedLimiter()
 	}
 
 	return nil
 }
-func (d *Download) initializeDownload() error {
-	d.Path = d.Destination + "/" + d.OutputFileName
 
+// initializeWithRetries retries the initialization after transient errors,
+// like the parts do with their requests. It returns errStopped when the
+// download is paused or stopped while it waits.
+func (d *Download) initializeWithRetries() error {
+	d.mu.Lock()
+	stop := d.stop
+	d.mu.Unlock()
+
+	for attempt := 0; ; attempt++ {
+		err := d.initializeDownload()
+		if err == nil || errors.Is(err, errStopped) || attempt >= d.retries || !isTransient(err) {
+			return err
+		}
+		var statusErr *statusError
+		if errors.As(err, &statusErr) && statusErr.isThrottled() {
+			d.hostLimiter().throttle(hostOf(d.URL), capRetryAfter(statusErr.RetryAfter, d.maxRetryAfter()))
+		}
+		if tooLong := checkRetryAfter(err, d.maxRetryAfter()); tooLong != nil {
+			return tooLong
+		}
+		delay := retryDelay(attempt, err)
+		log.Printf("Retrying initialization of downloadID = %d in %v (attempt %d of %d): %v\n", d.ID, delay, attempt+1, d.retries, err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-stop:
+			timer.Stop()
+			return errStopped
+		case <-timer.C:
+		}
+		if d.GetStatus() != Pending {
+			return errStopped
+		}
+	}
+}
+
+func (d *Download) initializeDownload() error {
 	err := d.setHttpResponse()
 	if err != nil {
 		return err
 	}
-	d.setTotalSize()
-
-	if d.TotalSize == 0 {
-		d.setStatus(Failed)
-		log.Printf("Content length in downloadID = %d is invalid\n", d.ID)
-		return errors.New("content length is invalid")
+	d.mu.Lock()
+	d.FinalURL = d.headResp.Request.URL.String()
+	d.mu.Unlock()
+	if d.NameFromServer {
+		d.resolveFileName()
 	}
+	d.Path = d.Destination + "/" + d.OutputFileName
+	d.setTotalSize()
+	d.ETag = d.headResp.Header.Get("ETag")
+	d.LastModified = d.headResp.Header.Get("Last-Modified")
 
-	if d.supportsPartialDownload() {
-		d.NumberOfParts = NUMBER_OF_PARTS
+	if d.TotalSize <= 0 {
+		// The file is streamed as a single part until the server closes it.
+		log.Printf("Content length in downloadID = %d is unknown\n", d.ID)
+		d.mu.Lock()
+		d.TotalSize = -1
+		d.mu.Unlock()
+		d.NumberOfParts = 1
+	} else if d.supportsPartialDownload() {
+		d.NumberOfParts = d.PartCount
+		if d.NumberOfParts == 0 {
+			d.NumberOfParts = NUMBER_OF_PARTS
+		}
+		// Do not split small files into parts below the minimum size.
+		d.NumberOfParts = int(max(1, min(int64(d.NumberOfParts), d.TotalSize/d.getMinPartSize())))
 	} else {
 		d.NumberOfParts = 1
 	}
-	d.Parts = make([]Part, d.NumberOfParts)
+	d.mu.Lock()
+	d.Parts = make([]*Part, d.NumberOfParts)
+	d.mu.Unlock()
 
 	err = d.initializeParts()
 	if err != nil {
@@ -213,22 +512,58 @@ func (d *Download) initializeDownload() error {
 }
 
 func (d *Download) Start(bandwidthLimiter *BandwidthLimiter) error {
+	err := d.start(bandwidthLimiter)
+	switch {
+	case errors.Is(err, errResourceChanged):
+		log.Printf("Remote file of downloadID = %d changed, restarting it from scratch\n", d.ID)
+		d.discard()
+		err = d.start(bandwidthLimiter)
+	case errors.Is(err, errRangesNotSupported):
+		log.Printf("Server of downloadID = %d ignores ranges, restarting it as a single part\n", d.ID)
+		d.RangesUnsupported = true
+		d.discard()
+		err = d.start(bandwidthLimiter)
+	}
+	return err
+}
+
+func (d *Download) start(bandwidthLimiter *BandwidthLimiter) error {
 	d.setStatus(Pending)
+	d.setFailureReason("")
+	d.mu.Lock()
+	d.stop = make(chan Status)
+	d.mu.Unlock()
+	resume := false
 	if !d.IsInitialized {
-		err := d.initializeDownload()
+		err := d.initializeWithRetries()
+		if errors.Is(err, errStopped) {
+			log.Printf("Initialization of downloadID = %d stopped\n", d.ID)
+			return err
+		}
 		if err != nil {
 			log.Printf("Error while initializing downloadID = %d:%v", d.ID, err)
-			d.setStatus(Failed)
+			d.setFailed(err)
 			return err
 		} else {
 			d.IsInitialized = true
 		}
+	} else if d.Preallocate {
+		resume = d.loadProgress()
+	}
+
+	if d.Preallocate {
+		err := d.openOutputFile(resume)
+		if err != nil {
+			d.setFailed(err)
+			return err
+		}
+		defer d.closeOutputFile()
 	}
 
 	err := d.initializeRequestOfParts()
 	if err != nil {
 		log.Printf("Error in initializing req field in parts of downloadID = %d: %v\n", d.ID, err)
-		d.setStatus(Failed)
+		d.setFailed(err)
 		return err
 	}
 
@@ -245,13 +580,48 @@ func (d *Download) Start(bandwidthLimiter *BandwidthLimiter) error {
 	err = d.downloadParts(bandwidthLimiter)
 	if err != nil {
 		log.Printf("Error in downloadParts() function for downloadID = %d : %v\n", d.ID, err)
+		if d.GetStatus() == Failed {
+			d.setFailureReason(err.Error())
+		}
+		if d.Preallocate && d.GetStatus() != Cancelled {
+			d.mu.Lock()
+			d.saveProgress()
+			d.mu.Unlock()
+		}
 		return err
 	}
 	log.Printf("All parts downloaded successfully")
-	err = d.mergeParts()
+	if d.TotalSize < 0 {
+		d.mu.Lock()
+		d.TotalSize = d.Parts[0].DownloadedBytes
+		d.mu.Unlock()
+		if d.Preallocate {
+			// A restarted stream may have left a longer tail behind.
+			err = d.file.Truncate(d.TotalSize)
+			if err != nil {
+				log.Printf("Error truncating preallocated file of downloadID = %d: %v\n", d.ID, err)
+				d.setFailed(err)
+				return err
+			}
+		}
+	}
+	if d.Preallocate {
+		d.closeOutputFile()
+		d.removeProgress()
+	} else {
+		err = d.mergeParts()
+		if err != nil {
+			log.Printf("Error in mergeParts() function for downloadID = %d : %v\n", d.ID, err)
+			d.setFailed(err)
+			return err
+		}
+	}
+
+	err = d.verifyChecksum()
 	if err != nil {
-		log.Printf("Error in mergeParts() function for downloadID = %d : %v\n", d.ID, err)
-		d.setStatus(Failed)
+		log.Printf("Error verifying checksum of downloadID = %d : %v\n", d.ID, err)
+		d.discard()
+		d.setFailed(err)
 		return err
 	}
 
@@ -259,11 +629,38 @@ func (d *Download) Start(bandwidthLimiter *BandwidthLimiter) error {
 	return nil
 }
 
+// discard removes the merged file and forgets the parts so that the next
+// Start downloads the whole file from scratch.
+func (d *Download) discard() {
+	err := os.Remove(d.Path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("Error deleting merged file of downloadID = %d: %v\n", d.ID, err)
+	}
+	for _, part := range d.getParts() {
+		if part.Path == "" {
+			continue
+		}
+		err := os.Remove(part.Path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("Error deleting .part file of partId = %d in downloadID = %d: %v\n", part.PartIndex, d.ID, err)
+		}
+	}
+	if d.Preallocate {
+		d.removeProgress()
+	}
+
+	d.mu.Lock()
+	d.Parts = nil
+	d.FinalURL = ""
+	d.IsInitialized = false
+	d.mu.Unlock()
+}
+
 func (d *Download) Pause() error {
 	log.Printf("Pausing downloadID = %d", d.ID)
 	d.setStatus(Paused)
-	for i := range d.Parts {
-		part := &d.Parts[i]
+	d.stopWaiting()
+	for _, part := range d.getParts() {
 		err := part.pause()
 		if err != nil {
 			log.Printf("Error while pausing download of partId %v", part.PartIndex)
@@ -276,8 +673,8 @@ func (d *Download) Pause() error {
 func (d *Download) Pend() error {
 	log.Printf("Pending downloadID = %d", d.ID)
 	d.setStatus(Pending)
-	for i := range d.Parts {
-		part := &d.Parts[i]
+	d.stopWaiting()
+	for _, part := range d.getParts() {
 		err := part.pend()
 		if err != nil {
 			log.Printf("Error while pending download of partId %v", part.PartIndex)
@@ -288,9 +685,21 @@ func (d *Download) Pend() error {
 }
 
 func (d *Download) Cancel() error {
+	wasCompleted := d.GetStatus() == Completed
 	d.setStatus(Cancelled)
-	for i := range d.Parts {
-		part := &d.Parts[i]
+	d.stopWaiting()
+	if d.Preallocate {
+		for _, part := range d.getParts() {
+			part.cancel()
+		}
+		d.removeProgress()
+		if d.IsInitialized && !wasCompleted {
+			d.discard()
+		}
+		return nil
+	}
+
+	for _, part := range d.getParts() {
 		err := part.cancel()
 		if err != nil {
 			log.Printf("Error canceling partId = %d while canceling downloadID = %d: %v\n", part.PartIndex, d.ID, err)
@@ -311,12 +720,50 @@ func (d *Download) Cancel() error {
 	return nil
 }
 
+// stopWaiting wakes a download that waits before retrying, so that it
+// notices it was paused or stopped.
+func (d *Download) stopWaiting() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.stop != nil {
+		close(d.stop)
+		d.stop = nil
+	}
+}
+
 func (d *Download) setStatus(status Status) {
 	d.mu.Lock()
 	d.Status = status
 	d.mu.Unlock()
 }
 
+func (d *Download) getMinPartSize() int64 {
+	if d.MinPartSize == 0 {
+		return MIN_PART_SIZE
+	}
+	return d.MinPartSize
+}
+
+func (d *Download) getParts() []*Part {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return slices.Clone(d.Parts)
+}
+
+func (d *Download) setFailed(err error) {
+	d.mu.Lock()
+	d.Status = Failed
+	d.FailureReason = err.Error()
+	d.mu.Unlock()
+}
+
+func (d *Download) setFailureReason(reason string) {
+	d.mu.Lock()
+	d.FailureReason = reason
+	d.mu.Unlock()
+}
+
 func (d *Download) monitorProgress() {
 	ticker := time.NewTicker(300 * time.Millisecond)
 	defer ticker.Stop()
@@ -324,7 +771,7 @@ func (d *Download) monitorProgress() {
 	isActive := true
 	for range ticker.C {
 		<-ticker.C
-		if d.Status != InProgress {
+		if d.GetStatus() != InProgress {
 			if isActive {
 				isActive = false
 			} else {
@@ -334,9 +781,10 @@ func (d *Download) monitorProgress() {
 
 		d.mu.Lock()
 		d.DownloadedSize = 0
-		for i := range d.Parts {
-			part := &d.Parts[i]
+		for _, part := range d.Parts {
+			part.mu.Lock()
 			d.DownloadedSize += part.DownloadedBytes
+			part.mu.Unlock()
 		}
 
 		now := time.Now()
@@ -351,8 +799,14 @@ func (d *Download) monitorProgress() {
 			d.currentSpeed = 0
 		}
 
-		percentage := float64(d.DownloadedSize) / float64(d.TotalSize) * 100
+		var percentage float64
+		if d.TotalSize > 0 {
+			percentage = float64(d.DownloadedSize) / float64(d.TotalSize) * 100
+		}
 		d.DownloadPercentage = percentage
+		if d.Preallocate && d.Status == InProgress {
+			d.saveProgress()
+		}
 
 		log.Printf("monitoring :: %.2f%% (%.2f MB/%.2f MB) - %.2f MB/s\n",
 			percentage, float64(d.DownloadedSize)/1000/1000, float64(d.TotalSize)/1000/1000, d.currentSpeed/1000/1000)
@@ -365,13 +819,92 @@ func (d *Download) GetQueueName() string {
 }
 
 func (d *Download) GetStatus() Status {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 	return d.Status
 }
 
+func (d *Download) GetFailureReason() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.FailureReason
+}
+
+func (d *Download) GetDownloadedSize() int64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.DownloadedSize
+}
+
+// GetTotalSize returns the size of the file, or a value below 1 when it is
+// not known.
+func (d *Download) GetTotalSize() int64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.TotalSize
+}
+
+// SetSpeedLimit changes the speed limit of the download, 0 for none.
+func (d *Download) SetSpeedLimit(rate int64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.SpeedLimit = rate
+	if d.limiter != nil {
+		d.limiter.SetRate(rate)
+	}
+}
+
+func (d *Download) GetSpeedLimit() int64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.SpeedLimit
+}
+
+// GetNotBefore returns the time before which the download is not started,
+// or the zero time when it may start at once.
+func (d *Download) GetNotBefore() time.Time {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.NotBefore
+}
+
+// GetDeadline returns the time after which the download fails instead of
+// starting, or the zero time when it has none.
+func (d *Download) GetDeadline() time.Time {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.Deadline
+}
+
+// hasStarted reports whether the download ever began, so that it has parts
+// or progress to resume.
+func (d *Download) hasStarted() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.IsInitialized || len(d.Parts) > 0 || d.DownloadedSize > 0
+}
+
+// speedLimiter returns the limiter the parts share on top of the queue's,
+// so the download keeps to its own speed limit.
+func (d *Download) speedLimiter() *BandwidthLimiter {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.limiter == nil {
+		d.limiter = NewBandwidthLimiter(d.SpeedLimit, nil)
+	}
+	return d.limiter
+}
+
 func (d *Download) GetTransferRate() float64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 	return d.currentSpeed
 }
 
 func (d *Download) GetProgress() float64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 	return d.DownloadPercentage
 }
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"log"
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// DEFAULT_FILE_NAME is used when neither the server nor the URL name the file.
+const DEFAULT_FILE_NAME = "download"
+
+// fileNameFromURL returns the percent-decoded last path segment of rawURL,
+// without the query string or fragment.
+func fileNameFromURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return sanitizeFileName(path.Base(u.Path))
+}
+
+// fileNameFromContentDisposition returns the filename parameter of a
+// Content-Disposition header, including the RFC 5987 filename* form.
+func fileNameFromContentDisposition(contentDisposition string) string {
+	if contentDisposition == "" {
+		return ""
+	}
+	_, params, err := mime.ParseMediaType(contentDisposition)
+	if err != nil {
+		return ""
+	}
+	return sanitizeFileName(params["filename"])
+}
+
+// sanitizeFileName drops any directories from a name sent by the server, so
+// it cannot point outside the queue's directory.
+func sanitizeFileName(name string) string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	switch name {
+	case ".", "..", "/":
+		return ""
+	}
+	return strings.TrimSpace(name)
+}
+
+// resolveFileName names the download after the server's Content-Disposition
+// or, failing that, after the final URL the request was redirected to.
+func (d *Download) resolveFileName() {
+	name := fileNameFromContentDisposition(d.headResp.Header.Get("Content-Disposition"))
+	if name == "" {
+		name = fileNameFromURL(d.FinalURL)
+	}
+	if name != "" && name != d.OutputFileName {
+		log.Printf("downloadID = %d is saved as %q\n", d.ID, name)
+		d.OutputFileName = name
+	}
+	d.NameFromServer = false
+}
+
+// requestURL returns the URL the parts download from, which skips the
+// redirects followed the first time.
+func (d *Download) requestURL() string {
+	if d.FinalURL != "" {
+		return d.FinalURL
+	}
+	return d.URL
+}
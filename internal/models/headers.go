@@ -0,0 +1,137 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// HEADER_SEPARATOR separates the headers in their one-line text form,
+// e.g. "Authorization: Bearer abc | Accept: */*".
+const HEADER_SEPARATOR = "|"
+
+// managedHeaders are set by the downloader itself for every part.
+var managedHeaders = []string{"Range", "If-Range", "Content-Length", "Host"}
+
+// credentialHeaderWords mark the names of custom headers that carry
+// credentials, such as X-Api-Key or X-Auth-Token.
+var credentialHeaderWords = []string{"auth", "cookie", "token", "key", "secret", "session", "password", "signature"}
+
+// MAX_REDIRECTS is how many redirects a request follows, as in net/http.
+const MAX_REDIRECTS = 10
+
+// ParseHeaders parses headers in the form "Name: value | Name2: value".
+func ParseHeaders(s string) (http.Header, error) {
+	header := http.Header{}
+	for _, field := range strings.Split(s, HEADER_SEPARATOR) {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		name, value, found := strings.Cut(field, ":")
+		name = strings.TrimSpace(name)
+		if !found || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, errors.New("invalid header " + field + ", expected Name: value")
+		}
+		name = http.CanonicalHeaderKey(name)
+		if slices.Contains(managedHeaders, name) {
+			return nil, errors.New("header " + name + " cannot be set")
+		}
+		header.Add(name, strings.TrimSpace(value))
+	}
+	return header, nil
+}
+
+// FormatHeaders is the inverse of ParseHeaders.
+func FormatHeaders(header http.Header) string {
+	var fields []string
+	for _, name := range slices.Sorted(maps.Keys(header)) {
+		for _, value := range header[name] {
+			fields = append(fields, name+": "+value)
+		}
+	}
+	return strings.Join(fields, " "+HEADER_SEPARATOR+" ")
+}
+
+// mergeHeaders returns the defaults overridden by header. Cookies are
+// combined instead, so a download can add cookies to the queue's.
+func mergeHeaders(defaults, header http.Header) http.Header {
+	merged := defaults.Clone()
+	if merged == nil {
+		merged = http.Header{}
+	}
+	for name, values := range header {
+		if name == "Cookie" && merged.Get("Cookie") != "" {
+			merged.Set("Cookie", merged.Get("Cookie")+"; "+strings.Join(values, "; "))
+			continue
+		}
+		merged[name] = slices.Clone(values)
+	}
+	if len(merged) == 0 {
+		return nil
+	}
+	return merged
+}
+
+// newRequest builds a request of the download, with its headers and the
+// proxy of its queue.
+func (d *Download) newRequest(method, rawURL string) (*http.Request, error) {
+	ctx := context.WithValue(context.Background(), proxyKey{}, d.proxy)
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	d.applyHeaders(req)
+	return req, nil
+}
+
+// applyHeaders adds the custom headers of the download to req, and the
+// stored credential of its host unless the headers already authenticate.
+// Headers carrying credentials are only sent to the host of the URL the
+// download was added with, not to the one it redirects to.
+func (d *Download) applyHeaders(req *http.Request) {
+	originalHost := ""
+	if u, err := url.Parse(d.URL); err == nil {
+		originalHost = u.Host
+	}
+	for name, values := range d.Headers {
+		if req.URL.Host != originalHost && isCredentialHeader(name) {
+			continue
+		}
+		req.Header[name] = slices.Clone(values)
+	}
+	if c, ok := d.credential(req.URL.Hostname()); ok {
+		c.Apply(req)
+	}
+}
+
+// isCredentialHeader reports whether the header named name carries
+// credentials.
+func isCredentialHeader(name string) bool {
+	name = strings.ToLower(name)
+	return slices.ContainsFunc(credentialHeaderWords, func(word string) bool {
+		return strings.Contains(name, word)
+	})
+}
+
+// checkRedirect drops the headers carrying credentials when a redirect
+// leads to another host. net/http only does so for Authorization and
+// Cookie, not for custom token headers.
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= MAX_REDIRECTS {
+		return errors.New("stopped after " + strconv.Itoa(MAX_REDIRECTS) + " redirects")
+	}
+	if req.URL.Host != via[0].URL.Host {
+		for name := range req.Header {
+			if isCredentialHeader(name) {
+				req.Header.Del(name)
+			}
+		}
+	}
+	return nil
+}
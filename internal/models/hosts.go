@@ -0,0 +1,135 @@
+package models
+
+import (
+	"sync"
+	"time"
+)
+
+// POLITENESS_PERIOD is how long a host that answered 429 or 503 keeps
+// getting fewer connections after its Retry-After has passed.
+const POLITENESS_PERIOD = 2 * time.Minute
+
+// hostLimiter keeps track of the connections open to every host, so that
+// all downloads stay within the connection limits together and slow down
+// together when a host asks them to.
+type hostLimiter struct {
+	mu     sync.Mutex
+	limits ConnectionLimits
+	active int
+	hosts  map[string]*hostState
+	// changed is closed whenever a connection is released or the limits
+	// change.
+	changed chan struct{}
+}
+
+type hostState struct {
+	active int
+	// No new connection is opened before resumeAt.
+	resumeAt time.Time
+	// Until politeUntil at most politeLimit connections are open at once.
+	politeUntil time.Time
+	politeLimit int
+}
+
+func newHostLimiter() *hostLimiter {
+	return &hostLimiter{
+		hosts:   make(map[string]*hostState),
+		changed: make(chan struct{}),
+	}
+}
+
+// setLimits applies new connection limits. Connections over them stay open,
+// but no new ones are opened until enough of them are released.
+func (l *hostLimiter) setLimits(limits ConnectionLimits) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.limits = limits
+	l.notify()
+}
+
+func (l *hostLimiter) state(host string) *hostState {
+	h, exists := l.hosts[host]
+	if !exists {
+		h = &hostState{}
+		l.hosts[host] = h
+	}
+	return h
+}
+
+func (l *hostLimiter) notify() {
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// acquire waits until a new connection to host may be opened. It gives up
+// when a status arrives on stop, and returns that status.
+func (l *hostLimiter) acquire(host string, stop <-chan Status) (Status, bool) {
+	for {
+		l.mu.Lock()
+		h := l.state(host)
+		now := time.Now()
+		// Waiting for a connection to be released needs no timeout.
+		wait := time.Duration(-1)
+		switch limit := l.limits.forHost(host); {
+		case now.Before(h.resumeAt):
+			wait = h.resumeAt.Sub(now)
+		case now.Before(h.politeUntil) && h.active >= h.politeLimit:
+			wait = h.politeUntil.Sub(now)
+		case limit > 0 && h.active >= limit:
+		case l.limits.Max > 0 && l.active >= l.limits.Max:
+		default:
+			h.active++
+			l.active++
+			l.mu.Unlock()
+			return InProgress, true
+		}
+		changed := l.changed
+		l.mu.Unlock()
+
+		var timeout <-chan time.Time
+		if wait >= 0 {
+			timeout = time.After(wait)
+		}
+		select {
+		case status := <-stop:
+			return status, false
+		case <-changed:
+		case <-timeout:
+		}
+	}
+}
+
+func (l *hostLimiter) release(host string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	h := l.state(host)
+	h.active--
+	l.active--
+	l.notify()
+
+	now := time.Now()
+	if h.active == 0 && !now.Before(h.resumeAt) && !now.Before(h.politeUntil) {
+		delete(l.hosts, host)
+	}
+}
+
+// throttle keeps new connections to host waiting for retryAfter, and then
+// halves the connections to it for POLITENESS_PERIOD.
+func (l *hostLimiter) throttle(host string, retryAfter time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	h := l.state(host)
+	now := time.Now()
+	if resumeAt := now.Add(retryAfter); resumeAt.After(h.resumeAt) {
+		h.resumeAt = resumeAt
+	}
+	limit := max(1, h.active/2)
+	if now.Before(h.politeUntil) {
+		limit = min(limit, h.politeLimit)
+	}
+	h.politeLimit = limit
+	h.politeUntil = h.resumeAt.Add(POLITENESS_PERIOD)
+}
@@ -1,31 +1,54 @@
 package models
 
 import (
+	"cmp"
 	"encoding/json"
 	"errors"
 	"log"
 	"maps"
+	"net/http"
 	"slices"
 	"sort"
-	"strings"
 	"sync"
 	"time"
 )
 
 type Manager struct {
-	mu        sync.Mutex
-	LastID    int
-	Downloads []*Download
-	Queues    map[string]*Queue
+	mu           sync.Mutex
+	LastID       int
+	Downloads    []*Download
+	Queues       map[string]*Queue
+	Proxy        ProxyConfig
+	MaxBandwidth int64
+	session      *session
 }
 
 func NewManager() *Manager {
 	return &Manager{
-		Queues: make(map[string]*Queue),
+		Queues:  make(map[string]*Queue),
+		session: newSession(),
 	}
 }
 
 func (m *Manager) Start() {
+	m.mu.Lock()
+	// Proxy passwords saved before there was a credential store move there.
+	if proxy, err := m.storeProxyCredentials(m.Proxy.URL); err == nil {
+		m.Proxy.URL = proxy
+	}
+	m.session.setProxy(m.Proxy)
+	m.session.bandwidth.setMax(m.MaxBandwidth)
+	for _, d := range m.Downloads {
+		d.session = m.session
+	}
+	for _, q := range m.Queues {
+		q.session = m.session
+		if proxy, err := m.storeProxyCredentials(q.Proxy); err == nil {
+			q.Proxy = proxy
+		}
+	}
+	m.mu.Unlock()
+
 	go m.monitorActiveHours()
 }
 
@@ -39,7 +62,7 @@ func (m *Manager) Stop() {
 	}
 }
 
-func (m *Manager) AddDownload(url, outputFileName, queueName string) error {
+func (m *Manager) AddDownload(url, outputFileName, queueName string, opts DownloadOptions) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -48,12 +71,50 @@ func (m *Manager) AddDownload(url, outputFileName, queueName string) error {
 		return errors.New("queue does not exist")
 	}
 
-	if outputFileName == "" {
-		split := strings.Split(url, "/")
-		outputFileName = split[len(split)-1]
+	if opts.NumParts < 0 {
+		return errors.New("number of parts cannot be negative")
+	}
+	if opts.SpeedLimit < 0 {
+		return errors.New("speed limit must be greater or equal to 0")
+	}
+	if !opts.Deadline.IsZero() {
+		if opts.Deadline.Before(time.Now()) {
+			return errors.New("deadline has already passed")
+		}
+		if opts.Deadline.Before(opts.NotBefore) {
+			return errors.New("deadline must not be before the not before time")
+		}
+	}
+	if opts.Checksum != "" {
+		if _, _, err := parseChecksum(opts.Checksum); err != nil {
+			return err
+		}
+	}
+
+	// Without a name the URL's is used until the server tells a better one.
+	nameFromServer := outputFileName == ""
+	if nameFromServer {
+		outputFileName = cmp.Or(fileNameFromURL(url), DEFAULT_FILE_NAME)
 	}
 
 	d := NewDownload(m.LastID, url, q.GetSavePath(), outputFileName, queueName)
+	d.NameFromServer = nameFromServer
+	d.Checksum = opts.Checksum
+	d.PartCount = opts.NumParts
+	if d.PartCount == 0 {
+		d.PartCount = q.GetNumParts()
+	}
+	d.MaxPartCount = q.GetMaxParts()
+	d.MinPartSize = q.GetMinPartSize()
+	d.Preallocate = opts.Preallocate
+	d.SpeedLimit = opts.SpeedLimit
+	d.NotBefore = opts.NotBefore
+	d.Deadline = opts.Deadline
+	d.Headers = mergeHeaders(m.queueHeaders(q), opts.Headers)
+	d.session = m.session
+	if err := m.storeCredentials(d); err != nil {
+		return err
+	}
 	m.LastID++
 
 	d.Pend()
@@ -141,6 +202,55 @@ func (m *Manager) ResumeDownload(id int) error {
 	return nil
 }
 
+// SetDownloadSpeedLimit changes the speed limit of a download, 0 for none.
+// A running download slows down or speeds up at once.
+func (m *Manager) SetDownloadSpeedLimit(id int, rate int64) error {
+	if rate < 0 {
+		return errors.New("speed limit must be greater or equal to 0")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var d *Download
+	for _, dl := range m.Downloads {
+		if dl.ID == id {
+			d = dl
+			break
+		}
+	}
+	if d == nil {
+		return errors.New("download does not exist")
+	}
+
+	d.SetSpeedLimit(rate)
+	log.Printf("speed limit of download %q set to %d B/s\n", d.URL, rate)
+	return nil
+}
+
+// SetMaxBandwidth limits the speed of all queues together to rate bytes per
+// second, 0 for no limit.
+func (m *Manager) SetMaxBandwidth(rate int64) error {
+	if rate < 0 {
+		return errors.New("speed limit must be greater or equal to 0")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.MaxBandwidth = rate
+	m.session.bandwidth.setMax(rate)
+	log.Printf("global speed limit set to %d B/s\n", rate)
+	return nil
+}
+
+func (m *Manager) GetMaxBandwidth() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.MaxBandwidth
+}
+
 func (m *Manager) GetDownloadList() []*DownloadInfo {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -148,7 +258,21 @@ func (m *Manager) GetDownloadList() []*DownloadInfo {
 	var list []*DownloadInfo
 
 	for _, d := range m.Downloads {
-		list = append(list, &DownloadInfo{d.ID, d.URL, d.GetQueueName(), d.GetTransferRate(), d.GetProgress(), d.GetStatus()})
+		list = append(list, &DownloadInfo{
+			ID:             d.ID,
+			URL:            d.URL,
+			QueueName:      d.GetQueueName(),
+			TransferRate:   d.GetTransferRate(),
+			Progress:       d.GetProgress(),
+			DownloadedSize: d.GetDownloadedSize(),
+			TotalSize:      d.GetTotalSize(),
+			FailureReason:  d.GetFailureReason(),
+			AuthKind:       d.GetAuthKind(),
+			SpeedLimit:     d.GetSpeedLimit(),
+			NotBefore:      d.GetNotBefore(),
+			Deadline:       d.GetDeadline(),
+			Status:         d.GetStatus(),
+		})
 	}
 
 	return list
@@ -165,6 +289,14 @@ func (m *Manager) AddQueue(qInfo QueueInfo) error {
 	if err := checkQueueInfo(qInfo); err != nil {
 		return err
 	}
+	headers, err := m.storeQueueCredentials(qInfo.Name, qInfo.Headers, false)
+	if err != nil {
+		return err
+	}
+	proxy, err := m.storeProxyCredentials(qInfo.Proxy)
+	if err != nil {
+		return err
+	}
 
 	q := NewQueue(
 		qInfo.Name,
@@ -174,7 +306,17 @@ func (m *Manager) AddQueue(qInfo QueueInfo) error {
 		qInfo.StartTime,
 		qInfo.EndTime,
 		qInfo.SpeedLimit,
+		qInfo.NumParts,
+		qInfo.MaxParts,
+		qInfo.MinPartSize,
+		headers,
+		proxy,
+		qInfo.BandwidthSchedule,
+		qInfo.Weekdays,
+		qInfo.DateRanges,
+		qInfo.TimeZone,
 	)
+	q.session = m.session
 	m.Queues[qInfo.Name] = q
 	log.Printf("added queue %q\n", q.Name)
 	return nil
@@ -198,6 +340,7 @@ func (m *Manager) RemoveQueue(queueName string) error {
 		return d.GetQueueName() == queueName
 	})
 	q.Stop() // TODO: error handling
+	m.removeQueueCredentials(queueName)
 
 	delete(m.Queues, queueName)
 	log.Printf("removed queue %q\n", queueName)
@@ -216,6 +359,14 @@ func (m *Manager) UpdateQueue(qInfo QueueInfo) error {
 	if err := checkQueueInfo(qInfo); err != nil {
 		return err
 	}
+	headers, err := m.storeQueueCredentials(qInfo.Name, qInfo.Headers, qInfo.AuthKind != "")
+	if err != nil {
+		return err
+	}
+	proxy, err := m.storeProxyCredentials(qInfo.Proxy)
+	if err != nil {
+		return err
+	}
 
 	q.UpdateConfig(
 		qInfo.TargetDirectory,
@@ -224,6 +375,15 @@ func (m *Manager) UpdateQueue(qInfo QueueInfo) error {
 		qInfo.StartTime,
 		qInfo.EndTime,
 		qInfo.SpeedLimit,
+		qInfo.NumParts,
+		qInfo.MaxParts,
+		qInfo.MinPartSize,
+		headers,
+		proxy,
+		qInfo.BandwidthSchedule,
+		qInfo.Weekdays,
+		qInfo.DateRanges,
+		qInfo.TimeZone,
 	)
 	return nil
 }
@@ -235,6 +395,30 @@ func checkQueueInfo(qInfo QueueInfo) error {
 	if qInfo.NumRetries < 0 {
 		return errors.New("retry count error")
 	}
+	if qInfo.NumParts < 0 {
+		return errors.New("part count error")
+	}
+	numParts := qInfo.NumParts
+	if numParts == 0 {
+		numParts = NUMBER_OF_PARTS
+	}
+	if qInfo.MaxParts != 0 && qInfo.MaxParts < numParts {
+		return errors.New("max part count must not be less than the part count")
+	}
+	if qInfo.MinPartSize < 0 {
+		return errors.New("min part size error")
+	}
+	if err := checkProxyURL(qInfo.Proxy); err != nil {
+		return err
+	}
+	for _, tier := range qInfo.BandwidthSchedule {
+		if tier.SpeedLimit < 0 {
+			return errors.New("speed limit of tier " + tier.StartTime.Format("15:04") + "-" + tier.EndTime.Format("15:04") + " must be greater or equal to 0")
+		}
+	}
+	if err := checkTimeZone(qInfo.TimeZone); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -245,14 +429,28 @@ func (m *Manager) GetQueueList() []*QueueInfo {
 	var list []*QueueInfo
 
 	for q := range maps.Values(m.Queues) {
+		override, untilTransition := q.GetOverride()
 		list = append(list, &QueueInfo{
-			Name:            q.Name,
-			TargetDirectory: q.GetSavePath(),
-			MaxParallel:     q.GetNumConcurrent(),
-			SpeedLimit:      q.MaxBandwidth,
-			NumRetries:      q.NumRetries,
-			StartTime:       q.StartTime,
-			EndTime:         q.EndTime,
+			Name:              q.Name,
+			TargetDirectory:   q.GetSavePath(),
+			MaxParallel:       q.GetNumConcurrent(),
+			SpeedLimit:        q.MaxBandwidth,
+			NumRetries:        q.NumRetries,
+			StartTime:         q.StartTime,
+			EndTime:           q.EndTime,
+			NumParts:          q.GetNumParts(),
+			MaxParts:          q.GetMaxParts(),
+			MinPartSize:       q.GetMinPartSize(),
+			Headers:           q.GetHeaders(),
+			AuthKind:          m.queueAuthKind(q),
+			Proxy:             q.GetProxy(),
+			BandwidthSchedule: q.GetBandwidthSchedule(),
+			Weekdays:          q.GetWeekdays(),
+			DateRanges:        q.GetDateRanges(),
+			TimeZone:          q.GetTimeZone(),
+			Override:          override,
+			OverrideUntil:     untilTransition,
+			Active:            q.IsActive(),
 		})
 	}
 
@@ -294,17 +492,44 @@ func (m *Manager) checkTimeAndActivate() {
 	defer m.mu.Unlock()
 
 	for q := range maps.Values(m.Queues) {
-		isActive := q.IsActive()
-		checkTime := q.CheckActiveTime(now)
-		if isActive && !checkTime {
-			m.pauseQueueDownloads(q.Name)
-			q.Stop()
-		}
-		if !isActive && checkTime {
-			queuedDownloads := m.getQueuePendingDownloads(q.Name)
-			q.Start(queuedDownloads)
-		}
+		m.activateQueue(q, now)
+	}
+}
+
+// activateQueue starts or stops the queue as it should be at now. m.mu must
+// be held.
+func (m *Manager) activateQueue(q *Queue, now time.Time) {
+	isActive := q.IsActive()
+	shouldRun := q.ShouldRun(now)
+	if isActive && !shouldRun {
+		m.pauseQueueDownloads(q.Name)
+		q.Stop()
+	}
+	if !isActive && shouldRun {
+		queuedDownloads := m.getQueuePendingDownloads(q.Name)
+		q.Start(queuedDownloads)
+	}
+	q.ApplyBandwidthSchedule(now)
+}
+
+// SetQueueOverride starts or stops a queue right away, regardless of its
+// schedule, either for good or until the schedule next starts or stops it.
+// OVERRIDE_NONE returns the queue to its schedule.
+func (m *Manager) SetQueueOverride(queueName, override string, untilTransition bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	q, exists := m.Queues[queueName]
+	if !exists {
+		return errors.New("queue does not exist")
+	}
+
+	now := time.Now()
+	if err := q.SetOverride(override, untilTransition, now); err != nil {
+		return err
 	}
+	m.activateQueue(q, now)
+	return nil
 }
 
 func (m *Manager) pauseQueueDownloads(qName string) {
@@ -334,20 +559,70 @@ func (m *Manager) GetJson() ([]byte, error) {
 }
 
 type DownloadInfo struct {
-	ID           int
-	URL          string
-	QueueName    string
-	TransferRate float64
-	Progress     float64
+	ID             int
+	URL            string
+	QueueName      string
+	TransferRate   float64
+	Progress       float64
+	DownloadedSize int64
+	TotalSize      int64
+	FailureReason  string
+	AuthKind       string
+	SpeedLimit     int64
+	NotBefore      time.Time
+	Deadline       time.Time
 	Status
 }
 
+// DownloadOptions holds the optional settings of a new download.
+type DownloadOptions struct {
+	// Checksum is the expected hash of the file, either as "algorithm:hex"
+	// (sha256, sha1 or md5) or as a bare hex digest.
+	Checksum string
+	// Preallocate makes the parts write straight into the destination file
+	// instead of into separate .part files that are merged afterwards.
+	Preallocate bool
+	// NumParts overrides the queue's number of parts when greater than 0.
+	NumParts int
+	// Headers are sent with every request of the download, on top of the
+	// queue's default headers.
+	Headers http.Header
+	// SpeedLimit caps the download at this many bytes per second, within
+	// the queue's limit, or 0 for no cap of its own.
+	SpeedLimit int64
+	// NotBefore keeps the queue from starting the download earlier, so it
+	// can be added ahead of the time the file is published.
+	NotBefore time.Time
+	// Deadline makes the download fail if the queue has not started it by
+	// then. The zero time means no deadline.
+	Deadline time.Time
+}
+
 type QueueInfo struct {
-	Name            string
-	TargetDirectory string
-	MaxParallel     int
-	SpeedLimit      int64
-	NumRetries      int
-	StartTime       time.Time
-	EndTime         time.Time
+	Name              string
+	TargetDirectory   string
+	MaxParallel       int
+	SpeedLimit        int64
+	NumRetries        int
+	StartTime         time.Time
+	EndTime           time.Time
+	NumParts          int
+	MaxParts          int
+	MinPartSize       int64
+	Headers           http.Header
+	Proxy             string
+	BandwidthSchedule []BandwidthTier
+	Weekdays          []time.Weekday
+	DateRanges        []DateRange
+	TimeZone          string
+	// Override is the manual override of the queue, OverrideUntil whether
+	// it ends at the next scheduled transition.
+	Override      string
+	OverrideUntil bool
+	Active        bool
+	// AuthKind tells how the downloads of the queue authenticate by
+	// default, without the secret. Updating the queue keeps its stored
+	// credentials unless its headers carry new ones, or removes them when
+	// AuthKind is "".
+	AuthKind string
 }
@@ -4,10 +4,21 @@ import (
 	"errors"
 	"io"
 	"log"
+	"math"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	errResourceChanged    = errors.New("remote file changed since the download started")
+	errRangesNotSupported = errors.New("server does not support range requests")
+	errStalled            = errors.New("connection stalled")
+	errStopped            = errors.New("download was paused or stopped")
 )
 
 type Part struct {
@@ -18,81 +29,237 @@ type Part struct {
 	RangeOfDownload string
 	Path            string
 	req             *http.Request
+	client          *http.Client
+	retries         int
+	hosts           *hostLimiter
+	limiter         *BandwidthLimiter
+	stallTimeout    time.Duration
+	maxRetryAfter   time.Duration
+	file            *os.File
+	startedAt       time.Time
+	startBytes      int64
 	mu              sync.Mutex
 	channel         chan Status
 	Status
 }
 
 func (p *Part) start(commonChannelOfParts chan connectionWithPart, bandwidthLimiter *BandwidthLimiter) {
-	if p.getStatus() == Completed {
+	// A part whose range is done may still be marked paused by older saves.
+	if p.getStatus() == Completed || p.remaining() == 0 {
+		p.setStatus(Completed)
 		commonChannelOfParts <- connectionWithPart{nil, Completed}
 		return
 	}
-	p.setStatus(InProgress)
+	p.mu.Lock()
+	p.Status = InProgress
+	p.startedAt = time.Now()
+	p.startBytes = p.DownloadedBytes
+	p.mu.Unlock()
+
+	if p.file == nil {
+		flag := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+		if p.DownloadedBytes == 0 {
+			flag |= os.O_TRUNC
+		}
+		file, err := os.OpenFile(p.Path, flag, 0644)
+		if err != nil {
+			log.Printf("Error opening part file with partId = %d: %v\n", p.PartIndex, err)
+			p.setStatus(Failed)
+			commonChannelOfParts <- connectionWithPart{err, Failed}
+			return
+		}
+		p.file = file
+		defer func() {
+			file.Close()
+			p.file = nil
+		}()
+	}
 
+	// Failures, stalls included, are retried on their own, so the other
+	// parts keep going. The count starts over whenever a connection made
+	// some progress.
+	attempt := 0
+	for {
+		downloadedBytes := p.getDownloadedBytes()
+		result := p.connect(bandwidthLimiter)
+		// A server asking to wait too long fails the part instead.
+		if tooLong := checkRetryAfter(result.error, p.maxRetryAfter); tooLong != nil {
+			result.error = tooLong
+		} else if result.Status == Failed && isTransient(result.error) {
+			progressed := p.getDownloadedBytes() > downloadedBytes
+			if progressed {
+				attempt = 0
+			}
+			// A connection that stalled after making progress is worth
+			// another, even in a queue without retries.
+			retries := p.retries
+			if progressed && errors.Is(result.error, errStalled) {
+				retries = max(retries, 1)
+			}
+			if attempt < retries {
+				delay := retryDelay(attempt, result.error)
+				attempt++
+				log.Printf("Retrying partId = %d in %v (attempt %d of %d): %v\n", p.PartIndex, delay, attempt, retries, result.error)
+				select {
+				case status := <-p.channel:
+					log.Printf("Stop downloading partIndex = %d due to it's status = %d", p.PartIndex, status)
+					commonChannelOfParts <- connectionWithPart{errors.New("part " + strconv.Itoa(p.PartIndex) + " has status = " + strconv.Itoa(int(status))), status}
+					return
+				case <-time.After(delay):
+				}
+				continue
+			}
+		}
+		if result.Status == Failed {
+			p.setStatus(Failed)
+		}
+		commonChannelOfParts <- result
+		return
+	}
+}
+
+// connect downloads the rest of the part over a new connection, until the
+// part is done, stopped, or the connection fails or stalls.
+func (p *Part) connect(bandwidthLimiter *BandwidthLimiter) connectionWithPart {
 	startByte := p.StartIndex + p.DownloadedBytes
-    p.RangeOfDownload = strconv.FormatInt(startByte, 10) + "-" + strconv.FormatInt(p.EndIndex, 10)
-	p.req.Header.Set("Range", "bytes="+p.RangeOfDownload)
-	log.Printf("downloading part %d started (bytes %d - %d)", p.PartIndex, p.StartIndex+p.DownloadedBytes, p.EndIndex)
+	p.RangeOfDownload = p.formatRange()
+	if p.isOpenEnded() && startByte == 0 {
+		p.req.Header.Del("Range")
+	} else {
+		p.req.Header.Set("Range", "bytes="+p.RangeOfDownload)
+	}
 
-	client := &http.Client{}
-	resp, err := client.Do(p.req)
+	host := p.req.URL.Hostname()
+	if status, ok := p.hosts.acquire(host, p.channel); !ok {
+		log.Printf("Stop downloading partIndex = %d due to it's status = %d", p.PartIndex, status)
+		return connectionWithPart{errors.New("part " + strconv.Itoa(p.PartIndex) + " has status = " + strconv.Itoa(int(status))), status}
+	}
+	defer p.hosts.release(host)
+	log.Printf("downloading part %d started (bytes %s)", p.PartIndex, p.RangeOfDownload)
+
+	resp, err := p.client.Do(p.req)
 	if err != nil {
 		log.Printf("Error performing http request for partId = %d: %v\n", p.PartIndex, err)
-		p.setStatus(Failed)
-		commonChannelOfParts <- connectionWithPart{err, Failed}
-		return
+		return connectionWithPart{err, Failed}
 	}
 	defer resp.Body.Close()
 
-	file, err := os.OpenFile(p.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	// Without a known size there is nothing to validate a resume against,
+	// so a full body simply restarts the part.
+	if p.isOpenEnded() && startByte > 0 && resp.StatusCode == http.StatusOK {
+		log.Printf("Restarting partId = %d from the beginning since the server sent the whole file\n", p.PartIndex)
+		p.mu.Lock()
+		p.DownloadedBytes = 0
+		if p.Path != "" {
+			err = p.file.Truncate(0)
+		}
+		p.mu.Unlock()
+		if err != nil {
+			log.Printf("Error truncating part file with partId = %d: %v\n", p.PartIndex, err)
+			return connectionWithPart{err, Failed}
+		}
+		startByte = 0
+	}
+
+	err = p.checkResponse(resp, startByte)
 	if err != nil {
-		log.Printf("Error opening part file with partId = %d: %v\n", p.PartIndex, err)
-		p.fail()
-		commonChannelOfParts <- connectionWithPart{err, Failed}
-		return
+		log.Printf("Error in response for partId = %d: %v\n", p.PartIndex, err)
+		var statusErr *statusError
+		if errors.As(err, &statusErr) && statusErr.isThrottled() {
+			log.Printf("Host %q asked to slow down, waiting %v before new connections\n", host, statusErr.RetryAfter)
+			p.hosts.throttle(host, capRetryAfter(statusErr.RetryAfter, p.maxRetryAfter))
+		}
+		return connectionWithPart{err, Failed}
 	}
-	defer file.Close()
 
-	buffer := make([]byte, 32*1024)
+	// The watchdog closes the body when a read makes no progress for too
+	// long, which unblocks the read below.
+	var stalled atomic.Bool
+	watchdog := time.AfterFunc(math.MaxInt64, func() {
+		stalled.Store(true)
+		resp.Body.Close()
+	})
+	defer watchdog.Stop()
+
+	buffer := make([]byte, min(bandwidthLimiter.readSize(), p.limiter.readSize()))
 	for {
 		select {
 		case status := <-p.channel:
 			log.Printf("Stop downloading partIndex = %d due to it's status = %d", p.PartIndex, status)
-			commonChannelOfParts <- connectionWithPart{errors.New("part " + strconv.Itoa(p.PartIndex) + " has status = " + strconv.Itoa(int(status))), status}
-			return
+			return connectionWithPart{errors.New("part " + strconv.Itoa(p.PartIndex) + " has status = " + strconv.Itoa(int(status))), status}
 		default:
-			bandwidthLimiter.WaitForToken()
+			if p.stallTimeout > 0 {
+				watchdog.Reset(p.stallTimeout)
+			}
 			n, err := resp.Body.Read(buffer)
+			watchdog.Stop()
 			// log.Printf("downloading partId = %d with n = %d and downloadedBytes = %d/%d", p.PartIndex, n, p.DownloadedBytes, p.EndIndex - p.StartIndex)
 			if n > 0 {
-				_, err := file.Write(buffer[:n])
+				err := p.write(buffer[:n])
 				if err != nil {
 					log.Printf("Error writing buffer to part file for partId = %d: %v\n", p.PartIndex, err)
-					p.fail()
-					commonChannelOfParts <- connectionWithPart{err, Failed}
-					return
+					return connectionWithPart{err, Failed}
 				}
-
-				p.addToDownloadedBytes(n)
+				bandwidthLimiter.WaitN(n)
+				p.limiter.WaitN(n)
+			}
+			// The body of a part with a known end must not stop short of it.
+			if err == io.EOF && p.remaining() > 0 {
+				err = io.ErrUnexpectedEOF
 			}
-			if err == io.EOF {
+			if err == io.EOF || p.remaining() == 0 {
 				log.Printf("Downloaded partIndex = %d (bytes %d - %d)", p.PartIndex, p.StartIndex, p.EndIndex)
 				p.setStatus(Completed)
-				commonChannelOfParts <- connectionWithPart{nil, Completed}
-				return
+				return connectionWithPart{nil, Completed}
+			}
+			if err != nil && stalled.Load() {
+				log.Printf("partId = %d received nothing for %v, dropping the connection at byte %d\n", p.PartIndex, p.stallTimeout, p.StartIndex+p.DownloadedBytes)
+				return connectionWithPart{errStalled, Failed}
 			}
 			if err != nil {
 				log.Printf("Error reading body of http request for partId = %d: %v\n", p.PartIndex, err)
-				p.fail()
-				commonChannelOfParts <- connectionWithPart{err, Failed}
-				return
+				return connectionWithPart{err, Failed}
 			}
 		}
 	}
 }
 
+// checkResponse makes sure the body starts at startByte of the same file the
+// other parts are downloading.
+func (p *Part) checkResponse(resp *http.Response, startByte int64) error {
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		if !strings.HasPrefix(resp.Header.Get("Content-Range"), "bytes "+strconv.FormatInt(startByte, 10)+"-") {
+			return errors.New("server returned a different range than requested")
+		}
+		return nil
+	case http.StatusOK:
+		// A full body is fine for a part that covers the whole file.
+		if startByte == 0 && (p.isOpenEnded() || resp.ContentLength == p.EndIndex+1) {
+			return nil
+		}
+		// The server either ignored the range or, when If-Range was sent,
+		// has a different file now. The validators of the response tell.
+		if ifRange := p.req.Header.Get("If-Range"); ifRange != "" && isResourceChanged(resp, ifRange) {
+			return errResourceChanged
+		}
+		return errRangesNotSupported
+	}
+	return newStatusError(resp)
+}
+
+func isResourceChanged(resp *http.Response, ifRange string) bool {
+	etag, lastModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	if etag == "" && lastModified == "" {
+		return false
+	}
+	return ifRange != etag && ifRange != lastModified
+}
+
 func (p *Part) pause() error {
+	if p.getStatus() == Completed {
+		return nil
+	}
 	if p.getStatus() == InProgress {
 		log.Printf("--------entering channel pause partId = %d", p.PartIndex)
 		p.channel <- Paused
@@ -104,6 +271,9 @@ func (p *Part) pause() error {
 }
 
 func (p *Part) pend() error {
+	if p.getStatus() == Completed {
+		return nil
+	}
 	if p.getStatus() == InProgress {
 		p.channel <- Pending
 	}
@@ -121,15 +291,6 @@ func (p *Part) cancel() error {
 	return nil
 }
 
-func (p *Part) fail() error {
-	if p.getStatus() == InProgress {
-		p.channel <- Failed
-	}
-	p.setStatus(Failed)
-	log.Printf("Failing download of part %d : %d bytes downloaded", p.PartIndex, p.DownloadedBytes)
-	return nil
-}
-
 func (p *Part) setStatus(status Status) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
@@ -137,6 +298,13 @@ func (p *Part) setStatus(status Status) {
 	p.Status = status
 }
 
+func (p *Part) getDownloadedBytes() int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.DownloadedBytes
+}
+
 func (p *Part) getStatus() Status {
 	p.mu.Lock()
 	defer p.mu.Unlock()
@@ -144,8 +312,81 @@ func (p *Part) getStatus() Status {
 	return p.Status
 }
 
-func (p *Part) addToDownloadedBytes(n int) {
+// write appends to the .part file, or writes at the part's offset when the
+// part shares the preallocated destination file. Bytes past EndIndex are
+// dropped, since the end of the range may have been handed to another part.
+func (p *Part) write(b []byte) error {
 	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	remaining := p.EndIndex + 1 - p.StartIndex - p.DownloadedBytes
+	if !p.isOpenEnded() && int64(len(b)) > remaining {
+		b = b[:remaining]
+	}
+
+	var n int
+	var err error
+	if p.Path == "" {
+		n, err = p.file.WriteAt(b, p.StartIndex+p.DownloadedBytes)
+	} else {
+		n, err = p.file.Write(b)
+	}
 	p.DownloadedBytes += int64(n)
-	p.mu.Unlock()
+	return err
+}
+
+// remaining returns the number of bytes left in the part, or -1 when the
+// part is open-ended.
+func (p *Part) remaining() int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.isOpenEnded() {
+		return -1
+	}
+	return p.EndIndex + 1 - p.StartIndex - p.DownloadedBytes
+}
+
+// isOpenEnded reports whether the part runs until the end of a file whose
+// size is unknown.
+func (p *Part) isOpenEnded() bool {
+	return p.EndIndex < 0
+}
+
+func (p *Part) formatRange() string {
+	start := strconv.FormatInt(p.StartIndex+p.DownloadedBytes, 10)
+	if p.isOpenEnded() {
+		return start + "-"
+	}
+	return start + "-" + strconv.FormatInt(p.EndIndex, 10)
+}
+
+// eta estimates the seconds left for the part at its current speed.
+func (p *Part) eta() float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	elapsed := time.Since(p.startedAt).Seconds()
+	downloaded := p.DownloadedBytes - p.startBytes
+	if elapsed <= 0 || downloaded <= 0 {
+		return math.Inf(1)
+	}
+	return float64(p.EndIndex+1-p.StartIndex-p.DownloadedBytes) / (float64(downloaded) / elapsed)
+}
+
+// split shrinks the part to the front half of its remaining range and
+// returns the back half. Both halves are at least minSize bytes long.
+func (p *Part) split(minSize int64) (int64, int64, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	next := p.StartIndex + p.DownloadedBytes
+	remaining := p.EndIndex + 1 - next
+	if remaining < 2*minSize {
+		return 0, 0, false
+	}
+
+	start, end := next+remaining/2, p.EndIndex
+	p.EndIndex = start - 1
+	return start, end, true
 }
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// stallingReader serves after bytes of r, then blocks until stop is closed.
+type stallingReader struct {
+	*bytes.Reader
+	after int64
+	read  int64
+	stop  <-chan struct{}
+}
+
+func (s *stallingReader) Read(b []byte) (int, error) {
+	if s.read >= s.after {
+		<-s.stop
+		return 0, io.ErrUnexpectedEOF
+	}
+	n, err := s.Reader.Read(b[:min(int64(len(b)), s.after-s.read)])
+	s.read += int64(n)
+	return n, err
+}
+
+func TestPartReconnectsAfterStall(t *testing.T) {
+	data := testData(1 << 20)
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var content io.ReadSeeker = bytes.NewReader(data)
+		// The first transfer stalls halfway through.
+		if r.Method == http.MethodGet && requests.Add(1) == 1 {
+			content = &stallingReader{Reader: bytes.NewReader(data), after: int64(len(data) / 2), stop: r.Context().Done()}
+		}
+		http.ServeContent(w, r, "file", time.Unix(1000, 0), content)
+	}))
+	defer server.Close()
+
+	// The queue has no retries, yet the stalled part still reconnects.
+	dir := t.TempDir()
+	m := newTestManager(t, dir)
+	config := DefaultConfig()
+	config.StallTimeout = Duration(300 * time.Millisecond)
+	m.SetConfig(config)
+	if err := m.AddDownload(server.URL+"/file.bin", "", "queue", DownloadOptions{NumParts: 1}); err != nil {
+		t.Fatal(err)
+	}
+	waitForDownloads(t, m, 10*time.Second)
+
+	checkFile(t, filepath.Join(dir, "file.bin"), data)
+	if requests.Load() != 2 {
+		t.Errorf("download took %d requests, want 2", requests.Load())
+	}
+}
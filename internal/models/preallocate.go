@@ -0,0 +1,151 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+)
+
+// In preallocated mode the destination file is created with its final size
+// up front and every part writes at its own offset, so no .part files are
+// merged at the end. Part progress is mirrored into a sidecar file next to
+// the destination, which is what resumes after a restart rely on.
+
+func (d *Download) sidecarPath() string {
+	return d.Path + ".progress"
+}
+
+// openOutputFile opens the preallocated destination for the parts to write
+// into. An existing file is only written into when resume is set, that is
+// when the progress of its parts was restored from a matching sidecar.
+// Otherwise the file is created or truncated and sized from scratch, so no
+// stale bytes of an older file are left in it.
+func (d *Download) openOutputFile(resume bool) error {
+	var file *os.File
+	var err error
+	if resume {
+		file, err = os.OpenFile(d.Path, os.O_WRONLY, 0644)
+	}
+	if !resume || errors.Is(err, os.ErrNotExist) {
+		d.resetPartsProgress()
+		file, err = os.Create(d.Path)
+		if err == nil && d.TotalSize > 0 {
+			err = file.Truncate(d.TotalSize)
+		}
+	}
+	if err != nil {
+		log.Printf("Error opening preallocated file of downloadID = %d: %v\n", d.ID, err)
+		if file != nil {
+			file.Close()
+		}
+		return err
+	}
+
+	d.file = file
+	return nil
+}
+
+func (d *Download) closeOutputFile() {
+	if d.file == nil {
+		return
+	}
+	err := d.file.Close()
+	if err != nil {
+		log.Printf("Error closing preallocated file of downloadID = %d: %v\n", d.ID, err)
+	}
+	d.file = nil
+}
+
+func (d *Download) resetPartsProgress() {
+	for i := range d.Parts {
+		d.Parts[i].DownloadedBytes = 0
+		d.Parts[i].Status = Pending
+	}
+}
+
+// saveProgress writes the parts to the sidecar file. The caller must hold d.mu.
+func (d *Download) saveProgress() {
+	parts := make([]Part, len(d.Parts))
+	for i, part := range d.Parts {
+		part.mu.Lock()
+		parts[i] = Part{
+			PartIndex:       part.PartIndex,
+			StartIndex:      part.StartIndex,
+			EndIndex:        part.EndIndex,
+			DownloadedBytes: part.DownloadedBytes,
+			Status:          part.Status,
+		}
+		part.mu.Unlock()
+	}
+
+	data, err := json.Marshal(parts)
+	if err != nil {
+		log.Printf("Error encoding progress of downloadID = %d: %v\n", d.ID, err)
+		return
+	}
+
+	tmp := d.sidecarPath() + ".tmp"
+	err = os.WriteFile(tmp, data, 0644)
+	if err == nil {
+		err = os.Rename(tmp, d.sidecarPath())
+	}
+	if err != nil {
+		log.Printf("Error saving progress of downloadID = %d: %v\n", d.ID, err)
+	}
+}
+
+// loadProgress restores the parts from the sidecar file, which is more
+// recent than the state saved with the manager. It reports whether a
+// sidecar matching the size of the download was found.
+func (d *Download) loadProgress() bool {
+	data, err := os.ReadFile(d.sidecarPath())
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("Error reading progress of downloadID = %d: %v\n", d.ID, err)
+		}
+		return false
+	}
+
+	var parts []Part
+	if err := json.Unmarshal(data, &parts); err != nil || len(parts) == 0 {
+		log.Printf("Ignoring invalid progress file of downloadID = %d: %v\n", d.ID, err)
+		return false
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.TotalSize > 0 {
+		end := int64(-1)
+		for i := range parts {
+			end = max(end, parts[i].EndIndex)
+		}
+		if end != d.TotalSize-1 {
+			log.Printf("Ignoring progress file of downloadID = %d made for another size\n", d.ID)
+			return false
+		}
+	}
+
+	d.Parts = make([]*Part, len(parts))
+	for i := range parts {
+		d.Parts[i] = &Part{
+			PartIndex:       parts[i].PartIndex,
+			StartIndex:      parts[i].StartIndex,
+			EndIndex:        parts[i].EndIndex,
+			DownloadedBytes: parts[i].DownloadedBytes,
+			Status:          parts[i].Status,
+		}
+		if d.Parts[i].Status != Completed {
+			d.Parts[i].Status = Pending
+		}
+	}
+	d.NumberOfParts = len(d.Parts)
+	return true
+}
+
+func (d *Download) removeProgress() {
+	err := os.Remove(d.sidecarPath())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("Error deleting progress file of downloadID = %d: %v\n", d.ID, err)
+	}
+}
@@ -0,0 +1,191 @@
+package models
+
+import (
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Kafsh-e-Mardane-Varzeshi-Hypo-Test-Team/CT_HW1/internal/credentials"
+)
+
+// PROXY_DIRECT as the proxy of a queue makes its downloads bypass the
+// global proxy.
+const PROXY_DIRECT = "direct"
+
+// ProxyConfig is the proxy every download goes through unless its queue
+// sets another one.
+type ProxyConfig struct {
+	// URL is an http://, https://, socks5:// or socks5h:// proxy, with an
+	// optional user:password@ that is moved into the credential store when
+	// there is one. When empty the HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+	// environment variables apply.
+	URL string
+	// NoProxy lists the hosts reached directly: a host name also matching
+	// its subdomains, a ".domain" matching only subdomains, an IP or a CIDR.
+	NoProxy []string
+}
+
+// proxyKey is the request context key of the queue's proxy override.
+type proxyKey struct{}
+
+func checkProxyURL(rawURL string) error {
+	if rawURL == "" || rawURL == PROXY_DIRECT {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return errors.New("invalid proxy URL")
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return errors.New("proxy URL must start with http://, https://, socks5:// or socks5h://")
+	}
+	if u.Host == "" {
+		return errors.New("proxy URL has no host")
+	}
+	return nil
+}
+
+func (m *Manager) SetProxy(proxy ProxyConfig) error {
+	if proxy.URL == PROXY_DIRECT {
+		return errors.New("leave the global proxy empty to connect directly")
+	}
+	if err := checkProxyURL(proxy.URL); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var err error
+	proxy.URL, err = m.storeProxyCredentials(proxy.URL)
+	if err != nil {
+		return err
+	}
+	m.Proxy = proxy
+	m.session.setProxy(proxy)
+	log.Printf("proxy set to %q\n", redactProxy(proxy.URL))
+	return nil
+}
+
+func (m *Manager) GetProxy() ProxyConfig {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.Proxy
+}
+
+func (s *session) setProxy(proxy ProxyConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.proxy = proxy
+}
+
+// proxyFor picks the proxy of a request: the override of the download's
+// queue, otherwise the global one, unless the host is in the no-proxy list.
+func (s *session) proxyFor(req *http.Request) (*url.URL, error) {
+	s.mu.Lock()
+	proxy := s.proxy
+	s.mu.Unlock()
+
+	rawURL := proxy.URL
+	if override, _ := req.Context().Value(proxyKey{}).(string); override != "" {
+		rawURL = override
+	}
+	if rawURL == PROXY_DIRECT || matchNoProxy(req.URL.Hostname(), proxy.NoProxy) {
+		return nil, nil
+	}
+	if rawURL == "" {
+		return http.ProxyFromEnvironment(req)
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	return s.proxyWithCredentials(u), nil
+}
+
+// proxyCredentialHost is the name the credentials of a proxy are stored
+// under, apart from those of the hosts downloaded from.
+func proxyCredentialHost(host string) string {
+	return "proxy:" + host
+}
+
+// storeProxyCredentials moves the user:password of a proxy URL into the
+// credential store, replacing those stored for the proxy before, and
+// returns the URL without them. m.mu must be held.
+func (m *Manager) storeProxyCredentials(rawURL string) (string, error) {
+	store := m.session.credentials
+	u, err := url.Parse(rawURL)
+	if store == nil || err != nil || u.User == nil {
+		return rawURL, nil
+	}
+	password, _ := u.User.Password()
+	err = store.Set(credentials.Credential{
+		Host:     proxyCredentialHost(u.Host),
+		Username: u.User.Username(),
+		Password: password,
+	})
+	if err != nil {
+		return "", err
+	}
+	u.User = nil
+	log.Printf("stored credentials of proxy %q\n", u.Host)
+	return u.String(), nil
+}
+
+// proxyWithCredentials adds the stored user and password of a proxy to its
+// URL, unless the URL has its own.
+func (s *session) proxyWithCredentials(u *url.URL) *url.URL {
+	if s.credentials == nil || u.User != nil {
+		return u
+	}
+	c, exists := s.credentials.Stored(proxyCredentialHost(u.Host))
+	if !exists {
+		return u
+	}
+	u.User = url.UserPassword(c.Username, c.Password)
+	return u
+}
+
+func matchNoProxy(host string, noProxy []string) bool {
+	host = strings.ToLower(host)
+	ip := net.ParseIP(host)
+	for _, entry := range noProxy {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		switch {
+		case entry == "":
+		case entry == "*":
+			return true
+		case strings.HasPrefix(entry, "."):
+			if strings.HasSuffix(host, entry) {
+				return true
+			}
+		case strings.Contains(entry, "/"):
+			_, network, err := net.ParseCIDR(entry)
+			if err == nil && ip != nil && network.Contains(ip) {
+				return true
+			}
+		default:
+			entry = strings.TrimPrefix(entry, "*.")
+			if host == entry || strings.HasSuffix(host, "."+entry) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// redactProxy hides the password of a proxy URL in logs.
+func redactProxy(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Redacted()
+}
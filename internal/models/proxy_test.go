@@ -0,0 +1,334 @@
+package models
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Kafsh-e-Mardane-Varzeshi-Hypo-Test-Team/CT_HW1/internal/credentials"
+)
+
+// testData returns n pseudo-random bytes, the same for every call.
+func testData(n int) []byte {
+	data := make([]byte, n)
+	rand.New(rand.NewSource(1)).Read(data)
+	return data
+}
+
+// newFileServer serves data as a file supporting ranges.
+func newFileServer(data []byte) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "file", time.Unix(1000, 0), bytes.NewReader(data))
+	}))
+}
+
+// newTestManager returns a started manager with a credential store in dir
+// and a queue named "queue" that is always running.
+func newTestManager(t *testing.T, dir string) *Manager {
+	t.Helper()
+	store, err := credentials.Load(filepath.Join(dir, "credentials.enc"), filepath.Join(dir, "credentials.key"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewManager()
+	m.SetCredentials(store)
+	m.Start()
+	t.Cleanup(m.Stop)
+	err = m.AddQueue(QueueInfo{Name: "queue", TargetDirectory: dir, MaxParallel: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetQueueOverride("queue", OVERRIDE_START, false); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// waitForDownloads waits until every download of m has stopped running and
+// fails the test unless all of them completed.
+func waitForDownloads(t *testing.T, m *Manager, timeout time.Duration) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for {
+		done := true
+		for _, d := range m.GetDownloadList() {
+			switch d.Status {
+			case Completed:
+			case Failed:
+				t.Fatalf("download %s failed: %s", d.URL, d.FailureReason)
+			default:
+				done = false
+			}
+		}
+		if done {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("downloads did not complete in time")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func checkFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("%s has %d bytes that differ from the %d served", path, len(got), len(data))
+	}
+}
+
+// pipe copies between the two connections until either is closed.
+func pipe(a, b net.Conn) {
+	go func() {
+		io.Copy(a, b)
+		a.Close()
+	}()
+	io.Copy(b, a)
+	b.Close()
+}
+
+// newConnectProxy starts an HTTP proxy that only tunnels CONNECT requests
+// carrying the basic credentials user and password, and counts them.
+func newConnectProxy(t *testing.T, user, password string, tunnels *atomic.Int32) *httptest.Server {
+	auth := "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password))
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			http.Error(w, "only CONNECT is supported", http.StatusMethodNotAllowed)
+			return
+		}
+		if r.Header.Get("Proxy-Authorization") != auth {
+			w.Header().Set("Proxy-Authenticate", "Basic")
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+		target, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			target.Close()
+			return
+		}
+		tunnels.Add(1)
+		conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+		pipe(conn, target)
+	}))
+	t.Cleanup(proxy.Close)
+	return proxy
+}
+
+// newSocks5Proxy starts a SOCKS5 proxy requiring user and password, and
+// counts the connections it makes. It returns the address it listens on.
+func newSocks5Proxy(t *testing.T, user, password string, tunnels *atomic.Int32) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				target, err := socks5Handshake(conn, user, password)
+				if err != nil {
+					conn.Close()
+					return
+				}
+				tunnels.Add(1)
+				pipe(conn, target)
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+// socks5Handshake serves the RFC 1928 and RFC 1929 handshake of a CONNECT
+// and returns the connection to the requested address.
+func socks5Handshake(conn net.Conn, user, password string) (net.Conn, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return nil, err
+	}
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return nil, err
+	}
+	if !bytes.Contains(methods, []byte{0x02}) {
+		conn.Write([]byte{0x05, 0xff})
+		return nil, io.ErrUnexpectedEOF
+	}
+	conn.Write([]byte{0x05, 0x02})
+
+	readString := func() (string, error) {
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return "", err
+		}
+		s := make([]byte, length[0])
+		_, err := io.ReadFull(conn, s)
+		return string(s), err
+	}
+	if _, err := io.ReadFull(conn, header[:1]); err != nil {
+		return nil, err
+	}
+	gotUser, err := readString()
+	if err != nil {
+		return nil, err
+	}
+	gotPassword, err := readString()
+	if err != nil {
+		return nil, err
+	}
+	if gotUser != user || gotPassword != password {
+		conn.Write([]byte{0x01, 0x01})
+		return nil, io.ErrUnexpectedEOF
+	}
+	conn.Write([]byte{0x01, 0x00})
+
+	request := make([]byte, 4)
+	if _, err := io.ReadFull(conn, request); err != nil {
+		return nil, err
+	}
+	var host string
+	switch request[3] {
+	case 0x01:
+		ip := make([]byte, 4)
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return nil, err
+		}
+		host = net.IP(ip).String()
+	case 0x03:
+		host, err = readString()
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, io.ErrUnexpectedEOF
+	}
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(conn, port); err != nil {
+		return nil, err
+	}
+	target, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))))
+	if err != nil {
+		conn.Write([]byte{0x05, 0x05, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+		return nil, err
+	}
+	conn.Write([]byte{0x05, 0x00, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+	return target, nil
+}
+
+func TestProxyConnect(t *testing.T) {
+	data := testData(3 << 20)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "file", time.Unix(1000, 0), bytes.NewReader(data))
+	}))
+	defer server.Close()
+	var tunnels atomic.Int32
+	proxy := newConnectProxy(t, "user", "secret", &tunnels)
+
+	dir := t.TempDir()
+	m := newTestManager(t, dir)
+	m.session.httpClient().Transport.(*http.Transport).TLSClientConfig = server.Client().Transport.(*http.Transport).TLSClientConfig
+
+	proxyURL := strings.Replace(proxy.URL, "http://", "http://user:secret@", 1)
+	if err := m.SetProxy(ProxyConfig{URL: proxyURL}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddDownload(server.URL+"/file.bin", "", "queue", DownloadOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	waitForDownloads(t, m, 10*time.Second)
+
+	checkFile(t, filepath.Join(dir, "file.bin"), data)
+	if tunnels.Load() == 0 {
+		t.Error("no request went through the proxy")
+	}
+	assertNoProxyPassword(t, m, proxy.URL)
+}
+
+func TestProxySocks5(t *testing.T) {
+	data := testData(3 << 20)
+	server := newFileServer(data)
+	defer server.Close()
+	var tunnels atomic.Int32
+	proxyAddr := newSocks5Proxy(t, "user", "secret", &tunnels)
+
+	dir := t.TempDir()
+	m := newTestManager(t, dir)
+	if err := m.SetProxy(ProxyConfig{URL: "socks5://user:secret@" + proxyAddr}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddDownload(server.URL+"/file.bin", "", "queue", DownloadOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	waitForDownloads(t, m, 10*time.Second)
+
+	checkFile(t, filepath.Join(dir, "file.bin"), data)
+	if tunnels.Load() == 0 {
+		t.Error("no connection went through the proxy")
+	}
+	assertNoProxyPassword(t, m, "socks5://"+proxyAddr)
+}
+
+func TestProxyNoProxy(t *testing.T) {
+	data := testData(1 << 20)
+	server := newFileServer(data)
+	defer server.Close()
+	var tunnels atomic.Int32
+	proxyAddr := newSocks5Proxy(t, "user", "secret", &tunnels)
+
+	dir := t.TempDir()
+	m := newTestManager(t, dir)
+	err := m.SetProxy(ProxyConfig{URL: "socks5://user:secret@" + proxyAddr, NoProxy: []string{"127.0.0.0/8"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddDownload(server.URL+"/file.bin", "", "queue", DownloadOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	waitForDownloads(t, m, 10*time.Second)
+
+	checkFile(t, filepath.Join(dir, "file.bin"), data)
+	if tunnels.Load() != 0 {
+		t.Errorf("%d connections went through the proxy despite the no-proxy list", tunnels.Load())
+	}
+}
+
+// assertNoProxyPassword checks that the proxy is saved as wantURL, without
+// its password, which the credential store keeps instead.
+func assertNoProxyPassword(t *testing.T, m *Manager, wantURL string) {
+	t.Helper()
+	if got := m.GetProxy().URL; got != wantURL {
+		t.Errorf("proxy saved as %q, want %q", got, wantURL)
+	}
+	state, err := m.GetJson()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(state, []byte("secret")) {
+		t.Error("proxy password is saved in plain text")
+	}
+}
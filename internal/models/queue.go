@@ -2,47 +2,122 @@ package models
 
 import (
 	"errors"
+	"fmt"
 	"log"
+	"net/http"
+	"slices"
 	"sync"
 	"time"
 )
 
+const (
+	// OVERRIDE_NONE leaves a queue to its schedule.
+	OVERRIDE_NONE = ""
+	// OVERRIDE_START keeps a queue running outside its schedule.
+	OVERRIDE_START = "start"
+	// OVERRIDE_STOP keeps a queue stopped within its schedule.
+	OVERRIDE_STOP = "stop"
+)
+
+// NUMBER_OF_RETRIES is how many times a queue retries a failed request of
+// a download unless told otherwise.
+const NUMBER_OF_RETRIES = 3
+
 type Queue struct {
 	Name         string
 	downloadChan chan *Download
 	done         chan struct{}
 	wg           sync.WaitGroup
 
-	mu            sync.Mutex
-	SavePath      string
-	NumConcurrent int
-	NumRetries    int
-	StartTime     time.Time
-	EndTime       time.Time
-	MaxBandwidth  int64
-	active        bool
+	mu                sync.Mutex
+	SavePath          string
+	NumConcurrent     int
+	NumRetries        int
+	StartTime         time.Time
+	EndTime           time.Time
+	MaxBandwidth      int64
+	NumParts          int
+	MaxParts          int
+	MinPartSize       int64
+	Headers           http.Header
+	Proxy             string
+	BandwidthSchedule []BandwidthTier
+	Weekdays          []time.Weekday
+	DateRanges        []DateRange
+	TimeZone          string
+	loc               *time.Location
+	active            bool
+	limiter           *BandwidthLimiter
+	workers           int
+	resized           chan struct{}
+	session           *session
+
+	// Override starts or stops the queue regardless of its schedule. With
+	// OverrideUntilTransition it only lasts until the schedule next
+	// changes from ScheduledActive, what it said when the override was set.
+	Override                string
+	OverrideUntilTransition bool
+	ScheduledActive         bool
 }
 
-func NewQueue(name, savePath string, numConcurrent, numRetries int, startTime, endTime time.Time, maxBandwidth int64) *Queue {
+func NewQueue(name, savePath string, numConcurrent, numRetries int, startTime, endTime time.Time, maxBandwidth int64, numParts, maxParts int, minPartSize int64, headers http.Header, proxy string, bandwidthSchedule []BandwidthTier, weekdays []time.Weekday, dateRanges []DateRange, timeZone string) *Queue {
 	return &Queue{
-		Name:          name,
-		SavePath:      savePath,
-		NumConcurrent: numConcurrent,
-		NumRetries:    numRetries,
-		StartTime:     startTime,
-		EndTime:       endTime,
-		MaxBandwidth:  maxBandwidth,
-		active:        false,
+		Name:              name,
+		SavePath:          savePath,
+		NumConcurrent:     numConcurrent,
+		NumRetries:        numRetries,
+		StartTime:         startTime,
+		EndTime:           endTime,
+		MaxBandwidth:      maxBandwidth,
+		NumParts:          numParts,
+		MaxParts:          maxParts,
+		MinPartSize:       minPartSize,
+		Headers:           headers,
+		Proxy:             proxy,
+		BandwidthSchedule: bandwidthSchedule,
+		Weekdays:          weekdays,
+		DateRanges:        dateRanges,
+		TimeZone:          timeZone,
+		loc:               loadLocation(timeZone),
+		active:            false,
 	}
 }
 
-func (q *Queue) UpdateConfig(savePath string, numConcurrent, numRetries int, startTime, endTime time.Time, maxBandwidth int64) {
+func (q *Queue) UpdateConfig(savePath string, numConcurrent, numRetries int, startTime, endTime time.Time, maxBandwidth int64, numParts, maxParts int, minPartSize int64, headers http.Header, proxy string, bandwidthSchedule []BandwidthTier, weekdays []time.Weekday, dateRanges []DateRange, timeZone string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
 	q.SavePath = savePath
 	q.NumConcurrent = numConcurrent
 	q.NumRetries = numRetries
 	q.StartTime = startTime
 	q.EndTime = endTime
 	q.MaxBandwidth = maxBandwidth
+	q.NumParts = numParts
+	q.MaxParts = maxParts
+	q.MinPartSize = minPartSize
+	q.Headers = headers
+	q.Proxy = proxy
+	q.BandwidthSchedule = bandwidthSchedule
+	q.Weekdays = weekdays
+	q.DateRanges = dateRanges
+	q.TimeZone = timeZone
+	q.loc = loadLocation(timeZone)
+
+	if !q.active {
+		return
+	}
+
+	// A running queue takes the new speed limit and worker count at once.
+	// Surplus workers leave once their download is done, so no transfer is
+	// interrupted.
+	q.applySpeedLimit(q.speedLimitAt(time.Now()))
+	for ; q.workers < numConcurrent; q.workers++ {
+		q.wg.Add(1)
+		go q.downloader(q.limiter)
+	}
+	close(q.resized)
+	q.resized = make(chan struct{})
 }
 
 func (q *Queue) AddDownload(d *Download) error {
@@ -76,7 +151,14 @@ func (q *Queue) Start(queuedDownloads []*Download) {
 	q.downloadChan = make(chan *Download, 100)
 	q.done = make(chan struct{})
 
-	bl := NewBandwidthLimiter(q.MaxBandwidth, q.done)
+	speedLimit := q.speedLimitAt(time.Now())
+	bl := NewBandwidthLimiter(speedLimit, q.done)
+	if q.session != nil {
+		q.session.bandwidth.register(bl, speedLimit)
+	}
+	q.limiter = bl
+	q.workers = q.NumConcurrent
+	q.resized = make(chan struct{})
 
 	q.wg.Add(q.NumConcurrent)
 	for i := 0; i < q.NumConcurrent; i++ {
@@ -101,42 +183,96 @@ func (q *Queue) downloader(bl *BandwidthLimiter) {
 	defer q.wg.Done()
 
 	for {
+		q.mu.Lock()
+		if q.workers > q.NumConcurrent {
+			q.workers--
+			q.mu.Unlock()
+			return
+		}
+		resized := q.resized
+		q.mu.Unlock()
+
 		select {
 		case d, ok := <-q.downloadChan:
 			if !ok {
 				break
 			}
 			if d.GetQueueName() == q.Name && d.GetStatus() == Pending {
-				for i := 0; i < q.NumRetries+1; i++ {
-					err := d.Start(bl)
-					if err == nil {
-						break
-					}
+				now := time.Now()
+				// A download that already began keeps its progress however
+				// late it is resumed.
+				if deadline := d.GetDeadline(); !deadline.IsZero() && now.After(deadline) && !d.hasStarted() {
+					log.Printf("download %q missed its deadline %v\n", d.URL, deadline)
+					d.setFailed(errors.New("not started before the deadline " + deadline.Format("2006-01-02 15:04")))
+					continue
+				}
+				if notBefore := d.GetNotBefore(); now.Before(notBefore) {
+					go q.addAt(d, notBefore, q.done)
+					continue
+				}
+				d.proxy = q.GetProxy()
+				d.retries = q.GetNumRetries()
+				err := d.Start(bl)
+				// Retrying requests cannot fix a corrupt file, so it is
+				// downloaded again from scratch.
+				for attempt := 0; errors.Is(err, errChecksumMismatch) && d.GetStatus() == Failed && attempt < d.retries; attempt++ {
+					log.Printf("Downloading downloadID = %d again after %v (attempt %d of %d)\n", d.ID, err, attempt+1, d.retries)
+					err = d.Start(bl)
+				}
+				if err != nil {
 					log.Println(err)
-
-					if d.GetStatus() != Failed {
-						break
-					}
 				}
 			}
+		case <-resized:
 		case <-q.done:
 			return
 		}
 	}
 }
 
+// ADD_RETRY_INTERVAL is how long a download waits to be handed back to a
+// queue whose channel is full.
+const ADD_RETRY_INTERVAL = time.Second
+
+// addAt hands a download that may not start yet back to the queue at the
+// given time, unless the queue stops first. It is added again when the
+// queue restarts, as it is still pending.
+func (q *Queue) addAt(d *Download, at time.Time, done <-chan struct{}) {
+	timer := time.NewTimer(time.Until(at))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			err := q.AddDownload(d)
+			if err == nil {
+				return
+			}
+			log.Printf("download %q is handed back to queue %q again in %v: %v\n", d.URL, q.Name, ADD_RETRY_INTERVAL, err)
+			timer.Reset(ADD_RETRY_INTERVAL)
+		case <-done:
+			return
+		}
+	}
+}
+
 func (q *Queue) Stop() {
 	q.mu.Lock()
-	defer q.mu.Unlock()
-
 	if !q.active {
+		q.mu.Unlock()
 		return
 	}
 
 	q.active = false
+	if q.session != nil {
+		q.session.bandwidth.unregister(q.limiter)
+	}
 
 	close(q.downloadChan)
 	close(q.done)
+	q.mu.Unlock()
+
+	// The workers lock the queue as well, so they are waited for without it.
 	q.wg.Wait()
 
 	log.Printf("queue %T stopped", q)
@@ -154,12 +290,95 @@ func (q *Queue) GetNumConcurrent() int {
 	return q.NumConcurrent
 }
 
+// GetNumRetries returns how many times a failed request of a download is
+// retried before the download fails.
+func (q *Queue) GetNumRetries() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.NumRetries
+}
+
 func (q *Queue) GetMaxBandwidth() int64 {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 	return q.MaxBandwidth
 }
 
+// GetNumParts returns the number of parts downloads of the queue are split
+// into, falling back to NUMBER_OF_PARTS when the queue does not set one.
+func (q *Queue) GetNumParts() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.NumParts == 0 {
+		return NUMBER_OF_PARTS
+	}
+	return q.NumParts
+}
+
+// GetMaxParts returns the ceiling up to which downloads of the queue add
+// connections while their throughput keeps rising, or 0 when downloads
+// keep their initial number of parts.
+func (q *Queue) GetMaxParts() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.MaxParts
+}
+
+func (q *Queue) GetMinPartSize() int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.MinPartSize == 0 {
+		return MIN_PART_SIZE
+	}
+	return q.MinPartSize
+}
+
+// GetHeaders returns the request headers downloads of the queue inherit.
+func (q *Queue) GetHeaders() http.Header {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.Headers.Clone()
+}
+
+// GetProxy returns the proxy overriding the global one for downloads of the
+// queue, PROXY_DIRECT to bypass it, or "" to use it.
+func (q *Queue) GetProxy() string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.Proxy
+}
+
+// GetBandwidthSchedule returns the tiers overriding the speed limit of the
+// queue during the day.
+func (q *Queue) GetBandwidthSchedule() []BandwidthTier {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return slices.Clone(q.BandwidthSchedule)
+}
+
+// GetWeekdays returns the days of the week the queue runs on, besides its
+// date ranges. With neither it runs every day.
+func (q *Queue) GetWeekdays() []time.Weekday {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return slices.Clone(q.Weekdays)
+}
+
+// GetDateRanges returns the dates the queue runs on, besides its weekdays.
+func (q *Queue) GetDateRanges() []DateRange {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return slices.Clone(q.DateRanges)
+}
+
+// GetTimeZone returns the time zone of the times of the queue, or "" for
+// the local one.
+func (q *Queue) GetTimeZone() string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.TimeZone
+}
+
 func (q *Queue) GetStartTime() time.Time {
 	q.mu.Lock()
 	defer q.mu.Unlock()
@@ -178,58 +397,69 @@ func (q *Queue) IsActive() bool {
 	return q.active
 }
 
+// CheckActiveTime reports whether the queue should run at now, in its own
+// time zone.
 func (q *Queue) CheckActiveTime(now time.Time) bool {
 	q.mu.Lock()
 	defer q.mu.Unlock()
-	endAfterStart := (q.EndTime.Hour() > q.StartTime.Hour()) || (q.EndTime.Hour() == q.StartTime.Hour() && q.EndTime.Minute() >= q.StartTime.Minute())
-	afterStart := (now.Hour() > q.StartTime.Hour()) || (now.Hour() == q.StartTime.Hour() && now.Minute() >= q.StartTime.Minute())
-	beforeEnd := (now.Hour() < q.EndTime.Hour()) || (now.Hour() == q.EndTime.Hour() && now.Minute() < q.EndTime.Minute())
-	log.Println(afterStart)
-	log.Println(beforeEnd)
-	if endAfterStart {
-		log.Println("endAfterStart")
-		return afterStart && beforeEnd
-	} else {
-		return afterStart || beforeEnd
-	}
-}
 
-type BandwidthLimiter struct {
-	rate   int64
-	tokens chan struct{}
-	stop   chan struct{}
+	now = now.In(q.location())
+	if !inDailyWindow(now, q.StartTime, q.EndTime) {
+		return false
+	}
+	// The part of a window past midnight belongs to the day it started on.
+	day := now
+	if now.Hour() < q.StartTime.Hour() || (now.Hour() == q.StartTime.Hour() && now.Minute() < q.StartTime.Minute()) {
+		day = now.AddDate(0, 0, -1)
+	}
+	return q.runsOn(day)
 }
 
-func NewBandwidthLimiter(rate int64, stop chan struct{}) *BandwidthLimiter {
-	bl := &BandwidthLimiter{
-		rate:   rate,
-		tokens: make(chan struct{}),
-		stop:   stop,
+// SetOverride starts or stops the queue by hand, or with OVERRIDE_NONE
+// returns it to its schedule. An override until the next transition is
+// dropped as soon as the schedule says otherwise than it does at now.
+func (q *Queue) SetOverride(override string, untilTransition bool, now time.Time) error {
+	switch override {
+	case OVERRIDE_NONE, OVERRIDE_START, OVERRIDE_STOP:
+	default:
+		return fmt.Errorf("invalid override %q", override)
 	}
+	scheduled := q.CheckActiveTime(now)
 
-	if rate > 0 {
-		go bl.generateTokens()
-	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.Override = override
+	q.OverrideUntilTransition = untilTransition && override != OVERRIDE_NONE
+	q.ScheduledActive = scheduled
+	return nil
+}
 
-	return bl
+// GetOverride returns the manual override of the queue and whether it only
+// lasts until the next scheduled transition.
+func (q *Queue) GetOverride() (string, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.Override, q.OverrideUntilTransition
 }
 
-func (bl *BandwidthLimiter) generateTokens() {
-	ticker := time.NewTicker(1024 * time.Second / time.Duration(bl.rate))
-	defer ticker.Stop()
+// ShouldRun reports whether the queue should run at now, by its override or
+// else by its schedule.
+func (q *Queue) ShouldRun(now time.Time) bool {
+	scheduled := q.CheckActiveTime(now)
 
-	for range ticker.C {
-		select {
-		case <-bl.stop:
-			return
-		case bl.tokens <- struct{}{}:
-		}
-	}
-}
+	q.mu.Lock()
+	defer q.mu.Unlock()
 
-func (bl *BandwidthLimiter) WaitForToken() {
-	if bl.rate == 0 {
-		return
+	if q.OverrideUntilTransition && scheduled != q.ScheduledActive {
+		log.Printf("override of queue %q ended at a scheduled transition\n", q.Name)
+		q.Override = OVERRIDE_NONE
+		q.OverrideUntilTransition = false
+	}
+	switch q.Override {
+	case OVERRIDE_START:
+		return true
+	case OVERRIDE_STOP:
+		return false
 	}
-	<-bl.tokens
+	return scheduled
 }
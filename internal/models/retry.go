@@ -0,0 +1,126 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// RETRY_BASE_DELAY is the wait before the first retry of a failed request.
+// Every further retry waits twice as long, up to RETRY_MAX_DELAY.
+const RETRY_BASE_DELAY = time.Second
+
+const RETRY_MAX_DELAY = time.Minute
+
+// statusError is a response whose status code the download cannot use.
+type statusError struct {
+	StatusCode int
+	Status     string
+	// RetryAfter is how long the server asked to wait before the next
+	// request, or 0 when it did not say.
+	RetryAfter time.Duration
+}
+
+func newStatusError(resp *http.Response) *statusError {
+	return &statusError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+}
+
+func (e *statusError) Error() string {
+	return "unexpected response status: " + e.Status
+}
+
+// isThrottled reports whether the server is rate limiting or overloaded,
+// in which case every connection to it should slow down.
+func (e *statusError) isThrottled() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode == http.StatusServiceUnavailable
+}
+
+// parseRetryAfter reads a Retry-After header, given either in seconds or as
+// an HTTP date.
+func parseRetryAfter(retryAfter string) time.Duration {
+	if retryAfter == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(retryAfter); err == nil {
+		return max(0, time.Duration(seconds)*time.Second)
+	}
+	if date, err := http.ParseTime(retryAfter); err == nil {
+		return max(0, time.Until(date))
+	}
+	return 0
+}
+
+// isTransient tells failures that may go away on their own, such as
+// timeouts, stalls, dropped connections and server errors, from those that will
+// not, such as a missing file or a refused authorization.
+func isTransient(err error) bool {
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		switch statusErr.StatusCode {
+		case http.StatusRequestTimeout, http.StatusTooManyRequests:
+			return true
+		}
+		return statusErr.StatusCode >= 500 && statusErr.StatusCode != http.StatusNotImplemented
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return true
+	}
+	return errors.Is(err, errStalled) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE)
+}
+
+// capRetryAfter limits a Retry-After to limit, unless limit is 0.
+func capRetryAfter(retryAfter, limit time.Duration) time.Duration {
+	if limit > 0 {
+		return min(retryAfter, limit)
+	}
+	return retryAfter
+}
+
+// checkRetryAfter returns an error when err asks to wait longer than limit
+// before the next request, so that the request fails as throttled instead
+// of waiting, or nil otherwise.
+func checkRetryAfter(err error, limit time.Duration) error {
+	var statusErr *statusError
+	if limit > 0 && errors.As(err, &statusErr) && statusErr.RetryAfter > limit {
+		return fmt.Errorf("%w, retry after %v is longer than the %v waited for at most", err, statusErr.RetryAfter, limit)
+	}
+	return nil
+}
+
+// retryDelay returns the wait before retry number attempt, counted from 0,
+// after err. It is an exponential backoff with jitter so parts do not retry
+// in lockstep, unless the server asked for a longer wait.
+func retryDelay(attempt int, err error) time.Duration {
+	delay := RETRY_MAX_DELAY
+	if attempt < 16 {
+		delay = min(RETRY_BASE_DELAY<<attempt, RETRY_MAX_DELAY)
+	}
+	delay = delay/2 + rand.N(delay/2+1)
+
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		delay = max(delay, statusErr.RetryAfter)
+	}
+	return delay
+}
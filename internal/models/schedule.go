@@ -0,0 +1,249 @@
+package models
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TIER_SEPARATOR separates the tiers of a bandwidth schedule in its
+// one-line text form, e.g. "09:00-17:00=204800, 22:00-06:00=0".
+const TIER_SEPARATOR = ","
+
+// BandwidthTier sets the speed limit of a queue during a time range of the
+// day. A range whose end is before its start runs past midnight.
+type BandwidthTier struct {
+	StartTime  time.Time
+	EndTime    time.Time
+	SpeedLimit int64
+}
+
+// ParseBandwidthSchedule parses tiers in the form "HH:MM-HH:MM=limit",
+// where the limit is in bytes per second and 0 means no limit.
+func ParseBandwidthSchedule(s string) ([]BandwidthTier, error) {
+	var schedule []BandwidthTier
+	for _, field := range strings.Split(s, TIER_SEPARATOR) {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		window, limit, found := strings.Cut(field, "=")
+		start, end, found2 := strings.Cut(window, "-")
+		if !found || !found2 {
+			return nil, errors.New("invalid tier " + field + ", expected HH:MM-HH:MM=limit")
+		}
+		st, err := time.Parse("15:04", strings.TrimSpace(start))
+		if err != nil {
+			return nil, errors.New("invalid start time in tier " + field + ". Must be in the format HH:MM")
+		}
+		et, err := time.Parse("15:04", strings.TrimSpace(end))
+		if err != nil {
+			return nil, errors.New("invalid end time in tier " + field + ". Must be in the format HH:MM")
+		}
+		if st.Equal(et) {
+			return nil, errors.New("tier " + field + " must not start and end at the same time")
+		}
+		sp, err := strconv.ParseInt(strings.TrimSpace(limit), 10, 64)
+		if err != nil || sp < 0 {
+			return nil, errors.New("speed limit of tier " + field + " must be a number greater or equal to 0")
+		}
+		schedule = append(schedule, BandwidthTier{StartTime: st, EndTime: et, SpeedLimit: sp})
+	}
+	return schedule, nil
+}
+
+// FormatBandwidthSchedule is the inverse of ParseBandwidthSchedule.
+func FormatBandwidthSchedule(schedule []BandwidthTier) string {
+	var fields []string
+	for _, tier := range schedule {
+		fields = append(fields, tier.StartTime.Format("15:04")+"-"+tier.EndTime.Format("15:04")+"="+strconv.FormatInt(tier.SpeedLimit, 10))
+	}
+	return strings.Join(fields, TIER_SEPARATOR+" ")
+}
+
+// inDailyWindow reports whether the time of day of now is between those of
+// start and end.
+func inDailyWindow(now, start, end time.Time) bool {
+	endAfterStart := (end.Hour() > start.Hour()) || (end.Hour() == start.Hour() && end.Minute() >= start.Minute())
+	afterStart := (now.Hour() > start.Hour()) || (now.Hour() == start.Hour() && now.Minute() >= start.Minute())
+	beforeEnd := (now.Hour() < end.Hour()) || (now.Hour() == end.Hour() && now.Minute() < end.Minute())
+	if endAfterStart {
+		return afterStart && beforeEnd
+	}
+	return afterStart || beforeEnd
+}
+
+// DateRange is a span of days, both ends included, during which a queue may
+// run besides its weekdays. Only the dates matter, not the times or locations.
+type DateRange struct {
+	Start time.Time
+	End   time.Time
+}
+
+// ParseWeekdays parses a comma separated list of weekdays, written as
+// English names or their first three letters.
+func ParseWeekdays(s string) ([]time.Weekday, error) {
+	var weekdays []time.Weekday
+	for _, field := range strings.Split(s, ",") {
+		field = strings.ToLower(strings.TrimSpace(field))
+		if field == "" {
+			continue
+		}
+		found := false
+		for day := time.Sunday; day <= time.Saturday; day++ {
+			name := strings.ToLower(day.String())
+			if field == name || field == name[:3] {
+				if !slices.Contains(weekdays, day) {
+					weekdays = append(weekdays, day)
+				}
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, errors.New("invalid weekday " + field)
+		}
+	}
+	slices.Sort(weekdays)
+	return weekdays, nil
+}
+
+// FormatWeekdays is the inverse of ParseWeekdays.
+func FormatWeekdays(weekdays []time.Weekday) string {
+	var fields []string
+	for _, day := range weekdays {
+		fields = append(fields, day.String()[:3])
+	}
+	return strings.Join(fields, ", ")
+}
+
+// ParseDateRanges parses a comma separated list of dates and date ranges,
+// e.g. "2026-03-20..2026-04-02, 2026-05-01".
+func ParseDateRanges(s string) ([]DateRange, error) {
+	var dateRanges []DateRange
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		start, end, found := strings.Cut(field, "..")
+		if !found {
+			end = start
+		}
+		sd, err := time.Parse(time.DateOnly, strings.TrimSpace(start))
+		if err != nil {
+			return nil, errors.New("invalid date in " + field + ". Must be in the format YYYY-MM-DD")
+		}
+		ed, err := time.Parse(time.DateOnly, strings.TrimSpace(end))
+		if err != nil {
+			return nil, errors.New("invalid date in " + field + ". Must be in the format YYYY-MM-DD")
+		}
+		if ed.Before(sd) {
+			return nil, errors.New("date range " + field + " ends before it starts")
+		}
+		dateRanges = append(dateRanges, DateRange{Start: sd, End: ed})
+	}
+	return dateRanges, nil
+}
+
+// FormatDateRanges is the inverse of ParseDateRanges.
+func FormatDateRanges(dateRanges []DateRange) string {
+	var fields []string
+	for _, r := range dateRanges {
+		field := r.Start.Format(time.DateOnly)
+		if !r.End.Equal(r.Start) {
+			field += ".." + r.End.Format(time.DateOnly)
+		}
+		fields = append(fields, field)
+	}
+	return strings.Join(fields, ", ")
+}
+
+// contains reports whether the date of day, in its own location, is in the
+// range.
+func (r DateRange) contains(day time.Time) bool {
+	date := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	return !date.Before(r.Start) && !date.After(r.End)
+}
+
+// checkTimeZone makes sure the time zone is empty, for the local one, or a
+// name such as "Asia/Tehran".
+func checkTimeZone(timeZone string) error {
+	if _, err := time.LoadLocation(timeZone); err != nil {
+		return errors.New("unknown time zone " + timeZone)
+	}
+	return nil
+}
+
+// loadLocation returns the time zone named timeZone, or the local one when
+// it is empty or unknown. time.LoadLocation would take "" for UTC.
+func loadLocation(timeZone string) *time.Location {
+	if timeZone == "" || timeZone == "Local" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(timeZone)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// location returns the time zone the times of the queue are given in. It
+// is resolved once, here for a queue loaded from JSON. q.mu must be held.
+func (q *Queue) location() *time.Location {
+	if q.loc == nil {
+		q.loc = loadLocation(q.TimeZone)
+	}
+	return q.loc
+}
+
+// runsOn reports whether the queue may run on the day of day: on one of its
+// weekdays or in one of its date ranges, or on any day without either.
+// q.mu must be held.
+func (q *Queue) runsOn(day time.Time) bool {
+	if len(q.Weekdays) == 0 && len(q.DateRanges) == 0 {
+		return true
+	}
+	if slices.Contains(q.Weekdays, day.Weekday()) {
+		return true
+	}
+	return slices.ContainsFunc(q.DateRanges, func(r DateRange) bool {
+		return r.contains(day)
+	})
+}
+
+// ApplyBandwidthSchedule sets the speed limit of a running queue to that of
+// the tier now falls in.
+func (q *Queue) ApplyBandwidthSchedule(now time.Time) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if !q.active {
+		return
+	}
+	q.applySpeedLimit(q.speedLimitAt(now))
+}
+
+// speedLimitAt returns the speed limit of the first tier now falls in, or
+// MaxBandwidth outside the tiers. q.mu must be held.
+func (q *Queue) speedLimitAt(now time.Time) int64 {
+	now = now.In(q.location())
+	for _, tier := range q.BandwidthSchedule {
+		if inDailyWindow(now, tier.StartTime, tier.EndTime) {
+			return tier.SpeedLimit
+		}
+	}
+	return q.MaxBandwidth
+}
+
+// applySpeedLimit retunes the limiter of the running queue without
+// interrupting its downloads. q.mu must be held.
+func (q *Queue) applySpeedLimit(rate int64) {
+	if q.session != nil {
+		q.session.bandwidth.setLimit(q.limiter, rate)
+	} else {
+		q.limiter.SetRate(rate)
+	}
+}
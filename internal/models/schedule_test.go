@@ -0,0 +1,75 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+// clock returns the time of day hour:minute, as the queue forms store it.
+func clock(hour, minute int) time.Time {
+	return time.Date(0, 1, 1, hour, minute, 0, 0, time.UTC)
+}
+
+func TestLoadLocation(t *testing.T) {
+	for _, timeZone := range []string{"", "Local", "Nowhere/Unknown"} {
+		if loc := loadLocation(timeZone); loc != time.Local {
+			t.Errorf("time zone %q loads as %v, want the local one", timeZone, loc)
+		}
+	}
+	if loc := loadLocation("Asia/Tehran"); loc.String() != "Asia/Tehran" {
+		t.Errorf("time zone Asia/Tehran loads as %v", loc)
+	}
+}
+
+func TestQueueCheckActiveTime(t *testing.T) {
+	tehran, err := time.LoadLocation("Asia/Tehran")
+	if err != nil {
+		t.Skip(err)
+	}
+	tests := []struct {
+		name     string
+		timeZone string
+		now      time.Time
+		want     bool
+	}{
+		{"local zone inside the window", "", time.Date(2026, 10, 18, 10, 30, 0, 0, time.Local), true},
+		{"local zone outside the window", "", time.Date(2026, 10, 18, 11, 30, 0, 0, time.Local), false},
+		{"own zone inside the window", "Asia/Tehran", time.Date(2026, 10, 18, 10, 30, 0, 0, tehran).UTC(), true},
+		{"own zone outside the window", "Asia/Tehran", time.Date(2026, 10, 18, 10, 30, 0, 0, time.UTC), false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			q := NewQueue("queue", "", 1, 0, clock(10, 0), clock(11, 0), 0, 0, 0, 0, nil, "", nil, nil, nil, test.timeZone)
+			if got := q.CheckActiveTime(test.now); got != test.want {
+				t.Errorf("queue is active at %v: %v, want %v", test.now, got, test.want)
+			}
+		})
+	}
+}
+
+func TestQueueSpeedLimitAt(t *testing.T) {
+	tehran, err := time.LoadLocation("Asia/Tehran")
+	if err != nil {
+		t.Skip(err)
+	}
+	schedule := []BandwidthTier{{StartTime: clock(9, 0), EndTime: clock(17, 0), SpeedLimit: 100 * 1024}}
+	tests := []struct {
+		name     string
+		timeZone string
+		now      time.Time
+		want     int64
+	}{
+		{"local zone inside the tier", "", time.Date(2026, 10, 18, 9, 30, 0, 0, time.Local), 100 * 1024},
+		{"local zone outside the tier", "", time.Date(2026, 10, 18, 8, 30, 0, 0, time.Local), 1024 * 1024},
+		{"own zone inside the tier", "Asia/Tehran", time.Date(2026, 10, 18, 9, 30, 0, 0, tehran).UTC(), 100 * 1024},
+		{"own zone outside the tier", "Asia/Tehran", time.Date(2026, 10, 18, 14, 0, 0, 0, time.UTC), 1024 * 1024},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			q := NewQueue("queue", "", 1, 0, clock(0, 0), clock(0, 0), 1024*1024, 0, 0, 0, nil, "", schedule, nil, nil, test.timeZone)
+			if got := q.speedLimitAt(test.now); got != test.want {
+				t.Errorf("speed limit at %v = %d, want %d", test.now, got, test.want)
+			}
+		})
+	}
+}
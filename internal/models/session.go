@@ -0,0 +1,254 @@
+package models
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/Kafsh-e-Mardane-Varzeshi-Hypo-Test-Team/CT_HW1/internal/credentials"
+)
+
+// session holds what the downloads of a manager share. Unlike the rest of
+// the manager it is not persisted.
+type session struct {
+	credentials *credentials.Store
+	hosts       *hostLimiter
+	bandwidth   *bandwidthAllocator
+
+	mu     sync.Mutex
+	config Config
+	client *http.Client
+	proxy  ProxyConfig
+}
+
+func newSession() *session {
+	s := &session{
+		hosts:     newHostLimiter(),
+		bandwidth: newBandwidthAllocator(),
+	}
+	s.setConfig(DefaultConfig())
+	return s
+}
+
+// setConfig replaces the HTTP client and the connection limits. Parts that
+// already started keep the previous client until they reconnect.
+func (s *session) setConfig(config Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.config = config
+	s.client = &http.Client{
+		Transport:     config.Transport.newTransport(s.proxyFor),
+		CheckRedirect: checkRedirect,
+	}
+	s.hosts.setLimits(config.Connections)
+}
+
+func (s *session) httpClient() *http.Client {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.client
+}
+
+func (s *session) getConfig() Config {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.config
+}
+
+// SetConfig applies the settings of the config file. It is meant to be
+// called before Start.
+func (m *Manager) SetConfig(config Config) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.session.setConfig(config)
+}
+
+// SetCredentials makes the downloads authenticate with the given store.
+func (m *Manager) SetCredentials(store *credentials.Store) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.session.credentials = store
+}
+
+// storeCredentials moves the headers of a new download that carry
+// credentials into the credential store, so they are not saved in plain
+// text with the download. They then apply to every download from the same
+// host, on top of what the store already kept for it.
+func (m *Manager) storeCredentials(d *Download) error {
+	if m.session.credentials == nil {
+		return nil
+	}
+	c, ok := credentials.FromHeaders(hostOf(d.URL), d.Headers, isCredentialHeader)
+	if !ok {
+		return nil
+	}
+	if err := m.session.credentials.Merge(c); err != nil {
+		return err
+	}
+
+	d.Headers = withoutCredentials(d.Headers)
+	log.Printf("stored %s credentials of host %q\n", c.Kind(), c.Host)
+	return nil
+}
+
+// queueCredentialHost is the name the default credentials of a queue are
+// stored under, as they are not tied to a host.
+func queueCredentialHost(queueName string) string {
+	return "queue:" + queueName
+}
+
+// storeQueueCredentials moves the default headers of a queue that carry
+// credentials into the credential store, replacing those stored for it
+// before, and returns the headers left to be saved with the queue. Without
+// such headers the stored credentials are kept if keep is set, or removed
+// otherwise. m.mu must be held.
+func (m *Manager) storeQueueCredentials(queueName string, headers http.Header, keep bool) (http.Header, error) {
+	if m.session.credentials == nil {
+		return headers, nil
+	}
+	c, ok := credentials.FromHeaders(queueCredentialHost(queueName), headers, isCredentialHeader)
+	if !ok {
+		if !keep {
+			m.removeQueueCredentials(queueName)
+		}
+		return headers, nil
+	}
+	if err := m.session.credentials.Set(c); err != nil {
+		return nil, err
+	}
+	log.Printf("stored %s credentials of queue %q\n", c.Kind(), queueName)
+	return withoutCredentials(headers), nil
+}
+
+// removeQueueCredentials forgets the stored default credentials of a queue.
+// m.mu must be held.
+func (m *Manager) removeQueueCredentials(queueName string) {
+	store := m.session.credentials
+	if store == nil {
+		return
+	}
+	if _, exists := store.Stored(queueCredentialHost(queueName)); exists {
+		if err := store.Remove(queueCredentialHost(queueName)); err != nil {
+			log.Printf("Error removing credentials of queue %q: %v\n", queueName, err)
+		}
+	}
+}
+
+// queueHeaders returns the default headers of a queue together with its
+// stored credentials. m.mu must be held.
+func (m *Manager) queueHeaders(q *Queue) http.Header {
+	headers := q.GetHeaders()
+	if m.session.credentials == nil {
+		return headers
+	}
+	c, exists := m.session.credentials.Stored(queueCredentialHost(q.Name))
+	if !exists {
+		return headers
+	}
+	if headers == nil {
+		headers = http.Header{}
+	}
+	c.AddTo(headers)
+	return headers
+}
+
+// queueAuthKind tells how the downloads of a queue authenticate by default,
+// without the secret, or returns "" when the queue stores no credentials.
+// m.mu must be held.
+func (m *Manager) queueAuthKind(q *Queue) string {
+	if m.session.credentials == nil {
+		return ""
+	}
+	c, exists := m.session.credentials.Stored(queueCredentialHost(q.Name))
+	if !exists {
+		return ""
+	}
+	return c.Kind()
+}
+
+// withoutCredentials returns header without the headers carrying
+// credentials, or nil when none are left.
+func withoutCredentials(header http.Header) http.Header {
+	header = header.Clone()
+	for name := range header {
+		if isCredentialHeader(name) {
+			header.Del(name)
+		}
+	}
+	if len(header) == 0 {
+		return nil
+	}
+	return header
+}
+
+// httpClient returns the client shared by the downloads of the manager.
+func (d *Download) httpClient() *http.Client {
+	if d.session == nil {
+		return &http.Client{CheckRedirect: checkRedirect}
+	}
+	return d.session.httpClient()
+}
+
+// stallTimeout returns how long parts wait for data before reconnecting.
+func (d *Download) stallTimeout() time.Duration {
+	if d.session == nil {
+		return time.Duration(DefaultConfig().StallTimeout)
+	}
+	return time.Duration(d.session.getConfig().StallTimeout)
+}
+
+// maxRetryAfter returns the longest Retry-After the requests wait for.
+func (d *Download) maxRetryAfter() time.Duration {
+	if d.session == nil {
+		return time.Duration(DefaultConfig().MaxRetryAfter)
+	}
+	return time.Duration(d.session.getConfig().MaxRetryAfter)
+}
+
+// hostLimiter returns the connection limits shared by the downloads of the
+// manager.
+func (d *Download) hostLimiter() *hostLimiter {
+	if d.session == nil {
+		return newHostLimiter()
+	}
+	return d.session.hosts
+}
+
+// credential returns the stored credential of host, if any.
+func (d *Download) credential(host string) (credentials.Credential, bool) {
+	if d.session == nil || d.session.credentials == nil {
+		return credentials.Credential{}, false
+	}
+	return d.session.credentials.Get(host)
+}
+
+// GetAuthKind tells how the download authenticates, without the secret:
+// "header" for its own headers, the kind of the stored credential otherwise,
+// or "" when it does not.
+func (d *Download) GetAuthKind() string {
+	for name := range d.Headers {
+		if isCredentialHeader(name) {
+			return "header"
+		}
+	}
+	d.mu.Lock()
+	host := hostOf(d.requestURL())
+	d.mu.Unlock()
+	c, _ := d.credential(host)
+	return c.Kind()
+}
+
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
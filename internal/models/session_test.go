@@ -0,0 +1,57 @@
+package models
+
+import (
+	"net/http"
+	"testing"
+)
+
+// queueInfo returns the info of the queue named name.
+func queueInfo(t *testing.T, m *Manager, name string) QueueInfo {
+	t.Helper()
+	for _, qInfo := range m.GetQueueList() {
+		if qInfo.Name == name {
+			return *qInfo
+		}
+	}
+	t.Fatalf("queue %q does not exist", name)
+	return QueueInfo{}
+}
+
+func TestQueueCredentials(t *testing.T) {
+	dir := t.TempDir()
+	m := newTestManager(t, dir)
+	qInfo := queueInfo(t, m, "queue")
+	qInfo.Headers = http.Header{"Authorization": {"Bearer secret"}, "Accept": {"*/*"}}
+	if err := m.UpdateQueue(qInfo); err != nil {
+		t.Fatal(err)
+	}
+
+	// The queue shows how it authenticates, but not the secret.
+	qInfo = queueInfo(t, m, "queue")
+	if qInfo.Headers.Get("Authorization") != "" || qInfo.Headers.Get("Accept") != "*/*" {
+		t.Errorf("queue shows headers %v", qInfo.Headers)
+	}
+	if qInfo.AuthKind != "bearer" {
+		t.Errorf("queue authenticates with %q, want bearer", qInfo.AuthKind)
+	}
+
+	// Saving it as shown keeps the stored credentials.
+	if err := m.UpdateQueue(qInfo); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddDownload("http://127.0.0.1:1/file.bin", "", "queue", DownloadOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if kind := m.Downloads[0].GetAuthKind(); kind != "bearer" {
+		t.Errorf("download authenticates with %q, want the queue's bearer token", kind)
+	}
+
+	// Without an AuthKind they are removed.
+	qInfo.AuthKind = ""
+	if err := m.UpdateQueue(qInfo); err != nil {
+		t.Fatal(err)
+	}
+	if kind := queueInfo(t, m, "queue").AuthKind; kind != "" {
+		t.Errorf("queue still authenticates with %q", kind)
+	}
+}
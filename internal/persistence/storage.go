@@ -19,7 +19,7 @@ func Load(filename string) (*models.Manager, error) {
 	defer file.Close()
 
 	decoder := json.NewDecoder(file)
-	m := &models.Manager{}
+	m := models.NewManager()
 	if err := decoder.Decode(m); err != nil {
 		return nil, err
 	}
@@ -34,3 +34,26 @@ func Save(filename string, jsonData []byte) error {
 	}
 	return nil
 }
+
+// LoadConfig reads the config file, writing one with the defaults when it
+// does not exist yet. Settings missing from the file keep their defaults.
+func LoadConfig(filename string) (models.Config, error) {
+	config := models.DefaultConfig()
+
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		data, err = json.MarshalIndent(config, "", "  ")
+		if err != nil {
+			return config, err
+		}
+		return config, Save(filename, data)
+	}
+	if err != nil {
+		return config, err
+	}
+
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, err
+	}
+	return config, nil
+}
@@ -3,7 +3,9 @@ package tui
 import (
 	"fmt"
 	"io"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Kafsh-e-Mardane-Varzeshi-Hypo-Test-Team/CT_HW1/internal/models"
 	"github.com/charmbracelet/bubbles/help"
@@ -85,6 +87,15 @@ type addDownloadTabField int
 const (
 	urlField addDownloadTabField = iota
 	filenameField
+	checksumField
+	preallocateField
+	numPartsField
+	downloadSpeedLimitField
+	notBeforeField
+	deadlineField
+	headersField
+	cookiesField
+	userAgentField
 	queueField
 	confirmDownloadField
 	cancelDownloadField
@@ -92,17 +103,26 @@ const (
 
 // AddDownloadTab Model
 type AddDownloadTab struct {
-	manager       *models.Manager
-	focusIndex    addDownloadTabField
-	urlInput      textinput.Model
-	filenameInput textinput.Model
-	queueList     list.Model
-	queues        []string
-	selectedQueue int
-	listExpanded  bool
-	help          help.Model
-	keys          addDownloadKeyMap
-	footerMessage string
+	manager         *models.Manager
+	focusIndex      addDownloadTabField
+	urlInput        textinput.Model
+	filenameInput   textinput.Model
+	checksumInput   textinput.Model
+	preallocate     bool
+	numPartsInput   textinput.Model
+	speedLimitInput textinput.Model
+	notBeforeInput  textinput.Model
+	deadlineInput   textinput.Model
+	headersInput    textinput.Model
+	cookiesInput    textinput.Model
+	userAgentInput  textinput.Model
+	queueList       list.Model
+	queues          []string
+	selectedQueue   int
+	listExpanded    bool
+	help            help.Model
+	keys            addDownloadKeyMap
+	footerMessage   string
 }
 
 func NewAddDownloadTab(manager *models.Manager) AddDownloadTab {
@@ -119,6 +139,54 @@ func NewAddDownloadTab(manager *models.Manager) AddDownloadTab {
 	filenameInput.TextStyle = noStyle
 	filenameInput.Cursor.Style = cursorStyle
 
+	checksumInput := textinput.New()
+	checksumInput.Placeholder = "(Optional) Expected checksum, e.g. sha256:<hex>"
+	checksumInput.PromptStyle = noStyle
+	checksumInput.TextStyle = noStyle
+	checksumInput.Cursor.Style = cursorStyle
+
+	numPartsInput := textinput.New()
+	numPartsInput.Placeholder = "(Optional) Number of connections, defaults to the queue's"
+	numPartsInput.PromptStyle = noStyle
+	numPartsInput.TextStyle = noStyle
+	numPartsInput.Cursor.Style = cursorStyle
+
+	speedLimitInput := textinput.New()
+	speedLimitInput.Placeholder = "(Optional) Speed limit of this download (Bytes per second), empty for no limit"
+	speedLimitInput.PromptStyle = noStyle
+	speedLimitInput.TextStyle = noStyle
+	speedLimitInput.Cursor.Style = cursorStyle
+
+	notBeforeInput := textinput.New()
+	notBeforeInput.Placeholder = "(Optional) Start no earlier than YYYY-MM-DD HH:MM"
+	notBeforeInput.PromptStyle = noStyle
+	notBeforeInput.TextStyle = noStyle
+	notBeforeInput.Cursor.Style = cursorStyle
+
+	deadlineInput := textinput.New()
+	deadlineInput.Placeholder = "(Optional) Give up unless started by YYYY-MM-DD HH:MM"
+	deadlineInput.PromptStyle = noStyle
+	deadlineInput.TextStyle = noStyle
+	deadlineInput.Cursor.Style = cursorStyle
+
+	headersInput := textinput.New()
+	headersInput.Placeholder = "(Optional) Name: value | Name2: value"
+	headersInput.PromptStyle = noStyle
+	headersInput.TextStyle = noStyle
+	headersInput.Cursor.Style = cursorStyle
+
+	cookiesInput := textinput.New()
+	cookiesInput.Placeholder = "(Optional) name=value; name2=value"
+	cookiesInput.PromptStyle = noStyle
+	cookiesInput.TextStyle = noStyle
+	cookiesInput.Cursor.Style = cursorStyle
+
+	userAgentInput := textinput.New()
+	userAgentInput.Placeholder = "(Optional) User-Agent to send"
+	userAgentInput.PromptStyle = noStyle
+	userAgentInput.TextStyle = noStyle
+	userAgentInput.Cursor.Style = cursorStyle
+
 	items := []list.Item{}
 	queues := []string{}
 
@@ -135,14 +203,22 @@ func NewAddDownloadTab(manager *models.Manager) AddDownloadTab {
 	help.FullSeparator = " \t "
 
 	addDownloadTab := AddDownloadTab{
-		manager:       manager,
-		urlInput:      urlInput,
-		filenameInput: filenameInput,
-		queueList:     queueList,
-		queues:        queues,
-		selectedQueue: 0,
-		focusIndex:    0,
-		help:          help,
+		manager:         manager,
+		urlInput:        urlInput,
+		filenameInput:   filenameInput,
+		checksumInput:   checksumInput,
+		numPartsInput:   numPartsInput,
+		speedLimitInput: speedLimitInput,
+		notBeforeInput:  notBeforeInput,
+		deadlineInput:   deadlineInput,
+		headersInput:    headersInput,
+		cookiesInput:    cookiesInput,
+		userAgentInput:  userAgentInput,
+		queueList:       queueList,
+		queues:          queues,
+		selectedQueue:   0,
+		focusIndex:      0,
+		help:            help,
 		keys: addDownloadKeyMap{
 			next: key.NewBinding(
 				key.WithKeys("tab"),
@@ -187,7 +263,7 @@ func (m AddDownloadTab) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case tea.KeyMsg:
 			switch msg.String() {
 			case "tab", "down":
-				m.focusIndex = min(m.focusIndex+1, 4)
+				m.focusIndex = min(m.focusIndex+1, cancelDownloadField)
 			case "up", "shift+tab":
 				m.focusIndex = max(m.focusIndex-1, 0)
 			case "ctrl+c":
@@ -201,7 +277,7 @@ func (m AddDownloadTab) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case tea.KeyMsg:
 			switch msg.String() {
 			case "tab", "down":
-				m.focusIndex = min(m.focusIndex+1, 4)
+				m.focusIndex = min(m.focusIndex+1, cancelDownloadField)
 			case "up", "shift+tab":
 				m.focusIndex = max(m.focusIndex-1, 0)
 			case "ctrl+c":
@@ -210,6 +286,132 @@ func (m AddDownloadTab) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.filenameInput, cmd = m.filenameInput.Update(msg)
 			}
 		}
+	case checksumField:
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			switch msg.String() {
+			case "tab", "down":
+				m.focusIndex = min(m.focusIndex+1, cancelDownloadField)
+			case "up", "shift+tab":
+				m.focusIndex = max(m.focusIndex-1, 0)
+			case "ctrl+c":
+				return m, tea.Quit
+			default:
+				m.checksumInput, cmd = m.checksumInput.Update(msg)
+			}
+		}
+	case preallocateField:
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			switch msg.String() {
+			case "tab", "down":
+				m.focusIndex = min(m.focusIndex+1, cancelDownloadField)
+			case "up", "shift+tab":
+				m.focusIndex = max(m.focusIndex-1, 0)
+			case "enter", " ":
+				m.preallocate = !m.preallocate
+			case "ctrl+c":
+				return m, tea.Quit
+			}
+		}
+	case numPartsField:
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			switch msg.String() {
+			case "tab", "down":
+				m.focusIndex = min(m.focusIndex+1, cancelDownloadField)
+			case "up", "shift+tab":
+				m.focusIndex = max(m.focusIndex-1, 0)
+			case "ctrl+c":
+				return m, tea.Quit
+			default:
+				m.numPartsInput, cmd = m.numPartsInput.Update(msg)
+			}
+		}
+	case downloadSpeedLimitField:
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			switch msg.String() {
+			case "tab", "down":
+				m.focusIndex = min(m.focusIndex+1, cancelDownloadField)
+			case "up", "shift+tab":
+				m.focusIndex = max(m.focusIndex-1, 0)
+			case "ctrl+c":
+				return m, tea.Quit
+			default:
+				m.speedLimitInput, cmd = m.speedLimitInput.Update(msg)
+			}
+		}
+	case notBeforeField:
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			switch msg.String() {
+			case "tab", "down":
+				m.focusIndex = min(m.focusIndex+1, cancelDownloadField)
+			case "up", "shift+tab":
+				m.focusIndex = max(m.focusIndex-1, 0)
+			case "ctrl+c":
+				return m, tea.Quit
+			default:
+				m.notBeforeInput, cmd = m.notBeforeInput.Update(msg)
+			}
+		}
+	case deadlineField:
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			switch msg.String() {
+			case "tab", "down":
+				m.focusIndex = min(m.focusIndex+1, cancelDownloadField)
+			case "up", "shift+tab":
+				m.focusIndex = max(m.focusIndex-1, 0)
+			case "ctrl+c":
+				return m, tea.Quit
+			default:
+				m.deadlineInput, cmd = m.deadlineInput.Update(msg)
+			}
+		}
+	case headersField:
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			switch msg.String() {
+			case "tab", "down":
+				m.focusIndex = min(m.focusIndex+1, cancelDownloadField)
+			case "up", "shift+tab":
+				m.focusIndex = max(m.focusIndex-1, 0)
+			case "ctrl+c":
+				return m, tea.Quit
+			default:
+				m.headersInput, cmd = m.headersInput.Update(msg)
+			}
+		}
+	case cookiesField:
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			switch msg.String() {
+			case "tab", "down":
+				m.focusIndex = min(m.focusIndex+1, cancelDownloadField)
+			case "up", "shift+tab":
+				m.focusIndex = max(m.focusIndex-1, 0)
+			case "ctrl+c":
+				return m, tea.Quit
+			default:
+				m.cookiesInput, cmd = m.cookiesInput.Update(msg)
+			}
+		}
+	case userAgentField:
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			switch msg.String() {
+			case "tab", "down":
+				m.focusIndex = min(m.focusIndex+1, cancelDownloadField)
+			case "up", "shift+tab":
+				m.focusIndex = max(m.focusIndex-1, 0)
+			case "ctrl+c":
+				return m, tea.Quit
+			default:
+				m.userAgentInput, cmd = m.userAgentInput.Update(msg)
+			}
+		}
 	case queueField:
 		switch msg := msg.(type) {
 		case tea.KeyMsg:
@@ -229,7 +431,7 @@ func (m AddDownloadTab) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			} else {
 				switch msg.String() {
 				case "tab", "down":
-					m.focusIndex = min(m.focusIndex+1, 4)
+					m.focusIndex = min(m.focusIndex+1, cancelDownloadField)
 				case "up", "shift+tab":
 					m.focusIndex = max(m.focusIndex-1, 0)
 				case "enter":
@@ -255,24 +457,35 @@ func (m AddDownloadTab) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 				queue := m.queues[m.selectedQueue]
 
-				var err error
-				if url == "" {
-					err = fmt.Errorf("URL cannot be empty")
-				} else {
-					err = m.manager.AddDownload(url, filename, queue)
+				opts, err := m.downloadOptions()
+				if err == nil {
+					if url == "" {
+						err = fmt.Errorf("URL cannot be empty")
+					} else {
+						err = m.manager.AddDownload(url, filename, queue, opts)
+					}
 				}
 
 				if err == nil {
 					m.footerMessage = "Download added successfully."
 					m.urlInput.SetValue("")
 					m.filenameInput.SetValue("")
+					m.checksumInput.SetValue("")
+					m.preallocate = false
+					m.numPartsInput.SetValue("")
+					m.speedLimitInput.SetValue("")
+					m.notBeforeInput.SetValue("")
+					m.deadlineInput.SetValue("")
+					m.headersInput.SetValue("")
+					m.cookiesInput.SetValue("")
+					m.userAgentInput.SetValue("")
 					m.selectedQueue = 0
 					m.focusIndex = 0
 				} else {
 					m.footerMessage = "Error adding download:" + err.Error()
 				}
 			case "tab", "right":
-				m.focusIndex = min(m.focusIndex+1, 4)
+				m.focusIndex = min(m.focusIndex+1, cancelDownloadField)
 				cmd = tea.Cmd(textinput.Blink)
 			case "up", "shift+tab":
 				m.focusIndex = max(m.focusIndex-1, 0)
@@ -288,6 +501,15 @@ func (m AddDownloadTab) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				// reset fields
 				m.urlInput.SetValue("")
 				m.filenameInput.SetValue("")
+				m.checksumInput.SetValue("")
+				m.preallocate = false
+				m.numPartsInput.SetValue("")
+				m.speedLimitInput.SetValue("")
+				m.notBeforeInput.SetValue("")
+				m.deadlineInput.SetValue("")
+				m.headersInput.SetValue("")
+				m.cookiesInput.SetValue("")
+				m.userAgentInput.SetValue("")
 				m.selectedQueue = 0
 				m.focusIndex = 0
 				m.footerMessage = ""
@@ -309,10 +531,34 @@ func (m AddDownloadTab) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 func (m *AddDownloadTab) updateFocus() {
 	m.urlInput.Blur()
 	m.filenameInput.Blur()
+	m.checksumInput.Blur()
+	m.numPartsInput.Blur()
+	m.speedLimitInput.Blur()
+	m.notBeforeInput.Blur()
+	m.deadlineInput.Blur()
+	m.headersInput.Blur()
+	m.cookiesInput.Blur()
+	m.userAgentInput.Blur()
 	m.urlInput.PromptStyle = noStyle
 	m.urlInput.TextStyle = noStyle
 	m.filenameInput.PromptStyle = noStyle
 	m.filenameInput.TextStyle = noStyle
+	m.checksumInput.PromptStyle = noStyle
+	m.checksumInput.TextStyle = noStyle
+	m.numPartsInput.PromptStyle = noStyle
+	m.numPartsInput.TextStyle = noStyle
+	m.speedLimitInput.PromptStyle = noStyle
+	m.speedLimitInput.TextStyle = noStyle
+	m.notBeforeInput.PromptStyle = noStyle
+	m.notBeforeInput.TextStyle = noStyle
+	m.deadlineInput.PromptStyle = noStyle
+	m.deadlineInput.TextStyle = noStyle
+	m.headersInput.PromptStyle = noStyle
+	m.headersInput.TextStyle = noStyle
+	m.cookiesInput.PromptStyle = noStyle
+	m.cookiesInput.TextStyle = noStyle
+	m.userAgentInput.PromptStyle = noStyle
+	m.userAgentInput.TextStyle = noStyle
 
 	switch m.focusIndex {
 	case urlField:
@@ -323,6 +569,38 @@ func (m *AddDownloadTab) updateFocus() {
 		m.filenameInput.Focus()
 		m.filenameInput.PromptStyle = focusedStyle
 		m.filenameInput.TextStyle = focusedStyle
+	case checksumField:
+		m.checksumInput.Focus()
+		m.checksumInput.PromptStyle = focusedStyle
+		m.checksumInput.TextStyle = focusedStyle
+	case numPartsField:
+		m.numPartsInput.Focus()
+		m.numPartsInput.PromptStyle = focusedStyle
+		m.numPartsInput.TextStyle = focusedStyle
+	case downloadSpeedLimitField:
+		m.speedLimitInput.Focus()
+		m.speedLimitInput.PromptStyle = focusedStyle
+		m.speedLimitInput.TextStyle = focusedStyle
+	case notBeforeField:
+		m.notBeforeInput.Focus()
+		m.notBeforeInput.PromptStyle = focusedStyle
+		m.notBeforeInput.TextStyle = focusedStyle
+	case deadlineField:
+		m.deadlineInput.Focus()
+		m.deadlineInput.PromptStyle = focusedStyle
+		m.deadlineInput.TextStyle = focusedStyle
+	case headersField:
+		m.headersInput.Focus()
+		m.headersInput.PromptStyle = focusedStyle
+		m.headersInput.TextStyle = focusedStyle
+	case cookiesField:
+		m.cookiesInput.Focus()
+		m.cookiesInput.PromptStyle = focusedStyle
+		m.cookiesInput.TextStyle = focusedStyle
+	case userAgentField:
+		m.userAgentInput.Focus()
+		m.userAgentInput.PromptStyle = focusedStyle
+		m.userAgentInput.TextStyle = focusedStyle
 	}
 }
 
@@ -371,11 +649,21 @@ func (m AddDownloadTab) View() string {
 		footerHelpText = m.help.View(m.keys)
 	}
 
+	preallocateDisplay := "[ ] write parts directly into the file"
+	if m.preallocate {
+		preallocateDisplay = "[x] write parts directly into the file"
+	}
+	if m.focusIndex == preallocateField {
+		preallocateDisplay = focusedStyle.Render(preallocateDisplay)
+	} else {
+		preallocateDisplay = noStyle.Render(preallocateDisplay)
+	}
+
 	buttonConfirm := blurredConfirm
 	buttonCancel := blurredCancel
-	if m.focusIndex == 3 {
+	if m.focusIndex == confirmDownloadField {
 		buttonConfirm = focusedConfirm
-	} else if m.focusIndex == 4 {
+	} else if m.focusIndex == cancelDownloadField {
 		buttonCancel = focusedCancel
 	}
 
@@ -396,6 +684,51 @@ func (m AddDownloadTab) View() string {
 						noStyle.Render("Filename: "),
 						m.filenameInput.View(),
 					),
+					lipgloss.JoinHorizontal(
+						lipgloss.Top,
+						noStyle.Render("Checksum: "),
+						m.checksumInput.View(),
+					),
+					lipgloss.JoinHorizontal(
+						lipgloss.Top,
+						noStyle.Render("Preallocate: "),
+						preallocateDisplay,
+					),
+					lipgloss.JoinHorizontal(
+						lipgloss.Top,
+						noStyle.Render("Connections: "),
+						m.numPartsInput.View(),
+					),
+					lipgloss.JoinHorizontal(
+						lipgloss.Top,
+						noStyle.Render("Speed Limit: "),
+						m.speedLimitInput.View(),
+					),
+					lipgloss.JoinHorizontal(
+						lipgloss.Top,
+						noStyle.Render("Not Before: "),
+						m.notBeforeInput.View(),
+					),
+					lipgloss.JoinHorizontal(
+						lipgloss.Top,
+						noStyle.Render("Deadline: "),
+						m.deadlineInput.View(),
+					),
+					lipgloss.JoinHorizontal(
+						lipgloss.Top,
+						noStyle.Render("Headers: "),
+						m.headersInput.View(),
+					),
+					lipgloss.JoinHorizontal(
+						lipgloss.Top,
+						noStyle.Render("Cookies: "),
+						m.cookiesInput.View(),
+					),
+					lipgloss.JoinHorizontal(
+						lipgloss.Top,
+						noStyle.Render("User-Agent: "),
+						m.userAgentInput.View(),
+					),
 					lipgloss.JoinHorizontal(
 						lipgloss.Top,
 						noStyle.Render("Destination Queue: "),
@@ -417,6 +750,63 @@ func (m AddDownloadTab) View() string {
 	return docStyle.Render(form)
 }
 
+func (m AddDownloadTab) downloadOptions() (models.DownloadOptions, error) {
+	opts := models.DownloadOptions{
+		Checksum:    m.checksumInput.Value(),
+		Preallocate: m.preallocate,
+	}
+
+	if m.numPartsInput.Value() != "" {
+		numParts, err := strconv.Atoi(m.numPartsInput.Value())
+		if err != nil || numParts < 1 {
+			return opts, fmt.Errorf("number of connections must be a number greater than 0")
+		}
+		opts.NumParts = numParts
+	}
+
+	speedLimit, err := parseSpeedLimit(m.speedLimitInput.Value())
+	if err != nil {
+		return opts, err
+	}
+	opts.SpeedLimit = speedLimit
+
+	opts.NotBefore, err = parseDateTime(m.notBeforeInput.Value())
+	if err != nil {
+		return opts, fmt.Errorf("not before: %v", err)
+	}
+	opts.Deadline, err = parseDateTime(m.deadlineInput.Value())
+	if err != nil {
+		return opts, fmt.Errorf("deadline: %v", err)
+	}
+
+	headers, err := models.ParseHeaders(m.headersInput.Value())
+	if err != nil {
+		return opts, err
+	}
+	if cookies := strings.TrimSpace(m.cookiesInput.Value()); cookies != "" {
+		headers.Set("Cookie", cookies)
+	}
+	if userAgent := strings.TrimSpace(m.userAgentInput.Value()); userAgent != "" {
+		headers.Set("User-Agent", userAgent)
+	}
+	opts.Headers = headers
+	return opts, nil
+}
+
+// parseDateTime parses a local date and time in the form YYYY-MM-DD HH:MM,
+// where empty means none.
+func parseDateTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02 15:04", s, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %s. Must be in the format YYYY-MM-DD HH:MM", s)
+	}
+	return t, nil
+}
+
 func (m *AddDownloadTab) updateChoices() {
 	queues := m.manager.GetQueueList()
 	items := []list.Item{}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Kafsh-e-Mardane-Varzeshi-Hypo-Test-Team/CT_HW1/internal/models"
@@ -41,26 +42,46 @@ const (
 	addNameField AddQueueField = iota
 	addTargetDirectoryField
 	addMaxParallelField
+	addRetriesField
 	addSpeedLimitField
 	addStartTimeField
 	addEndTimeField
+	addWeekdaysField
+	addDatesField
+	addTimeZoneField
+	addNumPartsField
+	addMaxPartsField
+	addMinPartSizeField
+	addHeadersField
+	addProxyField
+	addBandwidthScheduleField
 	addConfirmQueueField
 	addCancelQueueField
 )
 
 // AddQueueTab Model
 type AddQueueTab struct {
-	manager        *models.Manager
-	focusIndex     AddQueueField
-	nameInput      textinput.Model
-	targetDirInput textinput.Model
-	maxParallel    textinput.Model
-	speedLimit     textinput.Model
-	startTime      textinput.Model
-	endTime        textinput.Model
-	help           help.Model
-	keys           addQueueKeyMap
-	footerMessage  string
+	manager           *models.Manager
+	focusIndex        AddQueueField
+	nameInput         textinput.Model
+	targetDirInput    textinput.Model
+	maxParallel       textinput.Model
+	retries           textinput.Model
+	speedLimit        textinput.Model
+	startTime         textinput.Model
+	endTime           textinput.Model
+	weekdays          textinput.Model
+	dates             textinput.Model
+	timeZone          textinput.Model
+	numParts          textinput.Model
+	maxParts          textinput.Model
+	minPartSize       textinput.Model
+	headers           textinput.Model
+	proxy             textinput.Model
+	bandwidthSchedule textinput.Model
+	help              help.Model
+	keys              addQueueKeyMap
+	footerMessage     string
 }
 
 func NewAddQueueTab(manager *models.Manager) AddQueueTab {
@@ -83,6 +104,12 @@ func NewAddQueueTab(manager *models.Manager) AddQueueTab {
 	maxParallel.TextStyle = noStyle
 	maxParallel.Cursor.Style = cursorStyle
 
+	retries := textinput.New()
+	retries.Placeholder = "Enter retries of a failed request (empty for default)"
+	retries.PromptStyle = noStyle
+	retries.TextStyle = noStyle
+	retries.Cursor.Style = cursorStyle
+
 	speedLimit := textinput.New()
 	speedLimit.Placeholder = "Enter speed limit (Bytes per second) (0 for no limit)"
 	speedLimit.PromptStyle = noStyle
@@ -101,20 +128,84 @@ func NewAddQueueTab(manager *models.Manager) AddQueueTab {
 	endTime.TextStyle = noStyle
 	endTime.Cursor.Style = cursorStyle
 
+	weekdays := textinput.New()
+	weekdays.Placeholder = "Enter days to run on, e.g. Sat, Sun, Mon (empty with no dates for every day)"
+	weekdays.PromptStyle = noStyle
+	weekdays.TextStyle = noStyle
+	weekdays.Cursor.Style = cursorStyle
+
+	dates := textinput.New()
+	dates.Placeholder = "Enter dates to run on besides the days, e.g. 2026-03-20..2026-04-02, 2026-05-01"
+	dates.PromptStyle = noStyle
+	dates.TextStyle = noStyle
+	dates.Cursor.Style = cursorStyle
+
+	timeZone := textinput.New()
+	timeZone.Placeholder = "Enter time zone of the times, e.g. Asia/Tehran (empty for local)"
+	timeZone.PromptStyle = noStyle
+	timeZone.TextStyle = noStyle
+	timeZone.Cursor.Style = cursorStyle
+
+	numParts := textinput.New()
+	numParts.Placeholder = "Enter connections per download (empty for default)"
+	numParts.PromptStyle = noStyle
+	numParts.TextStyle = noStyle
+	numParts.Cursor.Style = cursorStyle
+
+	maxParts := textinput.New()
+	maxParts.Placeholder = "Enter max connections to grow to while speed rises (empty to disable)"
+	maxParts.PromptStyle = noStyle
+	maxParts.TextStyle = noStyle
+	maxParts.Cursor.Style = cursorStyle
+
+	minPartSize := textinput.New()
+	minPartSize.Placeholder = "Enter minimum part size (Bytes) (empty for default)"
+	minPartSize.PromptStyle = noStyle
+	minPartSize.TextStyle = noStyle
+	minPartSize.Cursor.Style = cursorStyle
+
+	headers := textinput.New()
+	headers.Placeholder = "Enter default headers, Name: value | Name2: value (optional)"
+	headers.PromptStyle = noStyle
+	headers.TextStyle = noStyle
+	headers.Cursor.Style = cursorStyle
+
+	proxy := textinput.New()
+	proxy.Placeholder = "Enter proxy URL, or direct to bypass the global proxy (empty for global)"
+	proxy.PromptStyle = noStyle
+	proxy.TextStyle = noStyle
+	proxy.Cursor.Style = cursorStyle
+
+	bandwidthSchedule := textinput.New()
+	bandwidthSchedule.Placeholder = "Enter speed limits by time of day, e.g. 09:00-17:00=204800, 22:00-06:00=0 (empty for none)"
+	bandwidthSchedule.PromptStyle = noStyle
+	bandwidthSchedule.TextStyle = noStyle
+	bandwidthSchedule.Cursor.Style = cursorStyle
+
 	help := help.New()
 	help.ShowAll = true
 	help.FullSeparator = " \t "
 
 	return AddQueueTab{
-		manager:        manager,
-		focusIndex:     addNameField,
-		nameInput:      nameInput,
-		targetDirInput: targetDirInput,
-		maxParallel:    maxParallel,
-		speedLimit:     speedLimit,
-		startTime:      startTime,
-		endTime:        endTime,
-		help:           help,
+		manager:           manager,
+		focusIndex:        addNameField,
+		nameInput:         nameInput,
+		targetDirInput:    targetDirInput,
+		maxParallel:       maxParallel,
+		retries:           retries,
+		speedLimit:        speedLimit,
+		startTime:         startTime,
+		endTime:           endTime,
+		weekdays:          weekdays,
+		dates:             dates,
+		timeZone:          timeZone,
+		numParts:          numParts,
+		maxParts:          maxParts,
+		minPartSize:       minPartSize,
+		headers:           headers,
+		proxy:             proxy,
+		bandwidthSchedule: bandwidthSchedule,
+		help:              help,
 		keys: addQueueKeyMap{
 			Next:       key.NewBinding(key.WithKeys("tab"), key.WithHelp("tab", "next field")),
 			Prev:       key.NewBinding(key.WithKeys("shift+tab"), key.WithHelp("shift+tab", "previous field")),
@@ -138,7 +229,7 @@ func (m AddQueueTab) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case tea.KeyMsg:
 			switch msg.String() {
 			case "tab", "down":
-				m.focusIndex = min(m.focusIndex+1, 7)
+				m.focusIndex = min(m.focusIndex+1, addCancelQueueField)
 			case "up", "shift+tab":
 				m.focusIndex = max(m.focusIndex-1, 0)
 			case "ctrl+c", "esc":
@@ -152,7 +243,7 @@ func (m AddQueueTab) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case tea.KeyMsg:
 			switch msg.String() {
 			case "tab", "down":
-				m.focusIndex = min(m.focusIndex+1, 7)
+				m.focusIndex = min(m.focusIndex+1, addCancelQueueField)
 			case "up", "shift+tab":
 				m.focusIndex = max(m.focusIndex-1, 0)
 			case "ctrl+c", "esc":
@@ -166,7 +257,7 @@ func (m AddQueueTab) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case tea.KeyMsg:
 			switch msg.String() {
 			case "tab", "down":
-				m.focusIndex = min(m.focusIndex+1, 7)
+				m.focusIndex = min(m.focusIndex+1, addCancelQueueField)
 			case "up", "shift+tab":
 				m.focusIndex = max(m.focusIndex-1, 0)
 			case "ctrl+c", "esc":
@@ -175,12 +266,26 @@ func (m AddQueueTab) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		}
 		m.maxParallel, cmd = m.maxParallel.Update(msg)
+	case addRetriesField:
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			switch msg.String() {
+			case "tab", "down":
+				m.focusIndex = min(m.focusIndex+1, addCancelQueueField)
+			case "up", "shift+tab":
+				m.focusIndex = max(m.focusIndex-1, 0)
+			case "ctrl+c", "esc":
+				m.resetForm()
+				return m, func() tea.Msg { return CloseChildMsg{} }
+			}
+		}
+		m.retries, cmd = m.retries.Update(msg)
 	case addSpeedLimitField:
 		switch msg := msg.(type) {
 		case tea.KeyMsg:
 			switch msg.String() {
 			case "tab", "down":
-				m.focusIndex = min(m.focusIndex+1, 7)
+				m.focusIndex = min(m.focusIndex+1, addCancelQueueField)
 			case "up", "shift+tab":
 				m.focusIndex = max(m.focusIndex-1, 0)
 			case "ctrl+c", "esc":
@@ -194,7 +299,7 @@ func (m AddQueueTab) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case tea.KeyMsg:
 			switch msg.String() {
 			case "tab", "down":
-				m.focusIndex = min(m.focusIndex+1, 7)
+				m.focusIndex = min(m.focusIndex+1, addCancelQueueField)
 			case "up", "shift+tab":
 				m.focusIndex = max(m.focusIndex-1, 0)
 			case "ctrl+c", "esc":
@@ -208,7 +313,7 @@ func (m AddQueueTab) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case tea.KeyMsg:
 			switch msg.String() {
 			case "tab", "down":
-				m.focusIndex = min(m.focusIndex+1, 7)
+				m.focusIndex = min(m.focusIndex+1, addCancelQueueField)
 			case "up", "shift+tab":
 				m.focusIndex = max(m.focusIndex-1, 0)
 			case "ctrl+c", "esc":
@@ -217,6 +322,132 @@ func (m AddQueueTab) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		}
 		m.endTime, cmd = m.endTime.Update(msg)
+	case addWeekdaysField:
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			switch msg.String() {
+			case "tab", "down":
+				m.focusIndex = min(m.focusIndex+1, addCancelQueueField)
+			case "up", "shift+tab":
+				m.focusIndex = max(m.focusIndex-1, 0)
+			case "ctrl+c", "esc":
+				m.resetForm()
+				return m, func() tea.Msg { return CloseChildMsg{} }
+			}
+		}
+		m.weekdays, cmd = m.weekdays.Update(msg)
+	case addDatesField:
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			switch msg.String() {
+			case "tab", "down":
+				m.focusIndex = min(m.focusIndex+1, addCancelQueueField)
+			case "up", "shift+tab":
+				m.focusIndex = max(m.focusIndex-1, 0)
+			case "ctrl+c", "esc":
+				m.resetForm()
+				return m, func() tea.Msg { return CloseChildMsg{} }
+			}
+		}
+		m.dates, cmd = m.dates.Update(msg)
+	case addTimeZoneField:
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			switch msg.String() {
+			case "tab", "down":
+				m.focusIndex = min(m.focusIndex+1, addCancelQueueField)
+			case "up", "shift+tab":
+				m.focusIndex = max(m.focusIndex-1, 0)
+			case "ctrl+c", "esc":
+				m.resetForm()
+				return m, func() tea.Msg { return CloseChildMsg{} }
+			}
+		}
+		m.timeZone, cmd = m.timeZone.Update(msg)
+	case addNumPartsField:
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			switch msg.String() {
+			case "tab", "down":
+				m.focusIndex = min(m.focusIndex+1, addCancelQueueField)
+			case "up", "shift+tab":
+				m.focusIndex = max(m.focusIndex-1, 0)
+			case "ctrl+c", "esc":
+				m.resetForm()
+				return m, func() tea.Msg { return CloseChildMsg{} }
+			}
+		}
+		m.numParts, cmd = m.numParts.Update(msg)
+	case addMaxPartsField:
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			switch msg.String() {
+			case "tab", "down":
+				m.focusIndex = min(m.focusIndex+1, addCancelQueueField)
+			case "up", "shift+tab":
+				m.focusIndex = max(m.focusIndex-1, 0)
+			case "ctrl+c", "esc":
+				m.resetForm()
+				return m, func() tea.Msg { return CloseChildMsg{} }
+			}
+		}
+		m.maxParts, cmd = m.maxParts.Update(msg)
+	case addMinPartSizeField:
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			switch msg.String() {
+			case "tab", "down":
+				m.focusIndex = min(m.focusIndex+1, addCancelQueueField)
+			case "up", "shift+tab":
+				m.focusIndex = max(m.focusIndex-1, 0)
+			case "ctrl+c", "esc":
+				m.resetForm()
+				return m, func() tea.Msg { return CloseChildMsg{} }
+			}
+		}
+		m.minPartSize, cmd = m.minPartSize.Update(msg)
+	case addHeadersField:
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			switch msg.String() {
+			case "tab", "down":
+				m.focusIndex = min(m.focusIndex+1, addCancelQueueField)
+			case "up", "shift+tab":
+				m.focusIndex = max(m.focusIndex-1, 0)
+			case "ctrl+c", "esc":
+				m.resetForm()
+				return m, func() tea.Msg { return CloseChildMsg{} }
+			}
+		}
+		m.headers, cmd = m.headers.Update(msg)
+	case addProxyField:
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			switch msg.String() {
+			case "tab", "down":
+				m.focusIndex = min(m.focusIndex+1, addCancelQueueField)
+			case "up", "shift+tab":
+				m.focusIndex = max(m.focusIndex-1, 0)
+			case "ctrl+c", "esc":
+				m.resetForm()
+				return m, func() tea.Msg { return CloseChildMsg{} }
+			}
+		}
+		m.proxy, cmd = m.proxy.Update(msg)
+	case addBandwidthScheduleField:
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			switch msg.String() {
+			case "tab", "down":
+				m.focusIndex = min(m.focusIndex+1, addCancelQueueField)
+			case "up", "shift+tab":
+				m.focusIndex = max(m.focusIndex-1, 0)
+			case "ctrl+c", "esc":
+				m.resetForm()
+				return m, func() tea.Msg { return CloseChildMsg{} }
+			}
+		}
+		m.bandwidthSchedule, cmd = m.bandwidthSchedule.Update(msg)
 	case addConfirmQueueField:
 		switch msg := msg.(type) {
 		case tea.KeyMsg:
@@ -225,11 +456,21 @@ func (m AddQueueTab) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				name := m.nameInput.Value()
 				targetDir := m.targetDirInput.Value()
 				maxParallel := m.maxParallel.Value()
+				retries := m.retries.Value()
 				speedLimit := m.speedLimit.Value()
 				startTime := m.startTime.Value()
 				endTime := m.endTime.Value()
-
-				queueInfo, err := makeQueueInfo(name, targetDir, maxParallel, speedLimit, startTime, endTime)
+				numParts := m.numParts.Value()
+				maxParts := m.maxParts.Value()
+				minPartSize := m.minPartSize.Value()
+				headers := m.headers.Value()
+				proxy := m.proxy.Value()
+				bandwidthSchedule := m.bandwidthSchedule.Value()
+				weekdays := m.weekdays.Value()
+				dates := m.dates.Value()
+				timeZone := m.timeZone.Value()
+
+				queueInfo, err := makeQueueInfo(name, targetDir, maxParallel, retries, speedLimit, startTime, endTime, numParts, maxParts, minPartSize, headers, proxy, bandwidthSchedule, weekdays, dates, timeZone)
 
 				if err != nil {
 					m.footerMessage = err.Error()
@@ -263,7 +504,7 @@ func (m AddQueueTab) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.resetForm()
 				return m, func() tea.Msg { return CloseChildMsg{} }
 			case "up":
-				m.focusIndex = addEndTimeField
+				m.focusIndex = addBandwidthScheduleField
 			case "left", "shift+tab":
 				m.focusIndex = addConfirmQueueField
 				cmd = tea.Cmd(textinput.Blink)
@@ -282,9 +523,19 @@ func (m *AddQueueTab) updateFocus() {
 	m.nameInput.Blur()
 	m.targetDirInput.Blur()
 	m.maxParallel.Blur()
+	m.retries.Blur()
 	m.speedLimit.Blur()
 	m.startTime.Blur()
 	m.endTime.Blur()
+	m.weekdays.Blur()
+	m.dates.Blur()
+	m.timeZone.Blur()
+	m.numParts.Blur()
+	m.maxParts.Blur()
+	m.minPartSize.Blur()
+	m.headers.Blur()
+	m.proxy.Blur()
+	m.bandwidthSchedule.Blur()
 
 	m.nameInput.PromptStyle = noStyle
 	m.nameInput.TextStyle = noStyle
@@ -292,12 +543,32 @@ func (m *AddQueueTab) updateFocus() {
 	m.targetDirInput.TextStyle = noStyle
 	m.maxParallel.PromptStyle = noStyle
 	m.maxParallel.TextStyle = noStyle
+	m.retries.PromptStyle = noStyle
+	m.retries.TextStyle = noStyle
 	m.speedLimit.PromptStyle = noStyle
 	m.speedLimit.TextStyle = noStyle
 	m.startTime.PromptStyle = noStyle
 	m.startTime.TextStyle = noStyle
 	m.endTime.PromptStyle = noStyle
 	m.endTime.TextStyle = noStyle
+	m.weekdays.PromptStyle = noStyle
+	m.weekdays.TextStyle = noStyle
+	m.dates.PromptStyle = noStyle
+	m.dates.TextStyle = noStyle
+	m.timeZone.PromptStyle = noStyle
+	m.timeZone.TextStyle = noStyle
+	m.numParts.PromptStyle = noStyle
+	m.numParts.TextStyle = noStyle
+	m.maxParts.PromptStyle = noStyle
+	m.maxParts.TextStyle = noStyle
+	m.minPartSize.PromptStyle = noStyle
+	m.minPartSize.TextStyle = noStyle
+	m.headers.PromptStyle = noStyle
+	m.headers.TextStyle = noStyle
+	m.proxy.PromptStyle = noStyle
+	m.proxy.TextStyle = noStyle
+	m.bandwidthSchedule.PromptStyle = noStyle
+	m.bandwidthSchedule.TextStyle = noStyle
 
 	switch m.focusIndex {
 	case addNameField:
@@ -312,6 +583,10 @@ func (m *AddQueueTab) updateFocus() {
 		m.maxParallel.Focus()
 		m.maxParallel.PromptStyle = focusedStyle
 		m.maxParallel.TextStyle = focusedStyle
+	case addRetriesField:
+		m.retries.Focus()
+		m.retries.PromptStyle = focusedStyle
+		m.retries.TextStyle = focusedStyle
 	case addSpeedLimitField:
 		m.speedLimit.Focus()
 		m.speedLimit.PromptStyle = focusedStyle
@@ -324,6 +599,42 @@ func (m *AddQueueTab) updateFocus() {
 		m.endTime.Focus()
 		m.endTime.PromptStyle = focusedStyle
 		m.endTime.TextStyle = focusedStyle
+	case addWeekdaysField:
+		m.weekdays.Focus()
+		m.weekdays.PromptStyle = focusedStyle
+		m.weekdays.TextStyle = focusedStyle
+	case addDatesField:
+		m.dates.Focus()
+		m.dates.PromptStyle = focusedStyle
+		m.dates.TextStyle = focusedStyle
+	case addTimeZoneField:
+		m.timeZone.Focus()
+		m.timeZone.PromptStyle = focusedStyle
+		m.timeZone.TextStyle = focusedStyle
+	case addNumPartsField:
+		m.numParts.Focus()
+		m.numParts.PromptStyle = focusedStyle
+		m.numParts.TextStyle = focusedStyle
+	case addMaxPartsField:
+		m.maxParts.Focus()
+		m.maxParts.PromptStyle = focusedStyle
+		m.maxParts.TextStyle = focusedStyle
+	case addMinPartSizeField:
+		m.minPartSize.Focus()
+		m.minPartSize.PromptStyle = focusedStyle
+		m.minPartSize.TextStyle = focusedStyle
+	case addHeadersField:
+		m.headers.Focus()
+		m.headers.PromptStyle = focusedStyle
+		m.headers.TextStyle = focusedStyle
+	case addProxyField:
+		m.proxy.Focus()
+		m.proxy.PromptStyle = focusedStyle
+		m.proxy.TextStyle = focusedStyle
+	case addBandwidthScheduleField:
+		m.bandwidthSchedule.Focus()
+		m.bandwidthSchedule.PromptStyle = focusedStyle
+		m.bandwidthSchedule.TextStyle = focusedStyle
 	}
 }
 
@@ -378,6 +689,11 @@ func (m AddQueueTab) View() string {
 						noStyle.Render("Max Parallel Downloads: "),
 						m.maxParallel.View(),
 					),
+					lipgloss.JoinHorizontal(
+						lipgloss.Top,
+						noStyle.Render("Retries: "),
+						m.retries.View(),
+					),
 					lipgloss.JoinHorizontal(
 						lipgloss.Top,
 						noStyle.Render("Speed Limit: "),
@@ -393,6 +709,51 @@ func (m AddQueueTab) View() string {
 						noStyle.Render("End Time: "),
 						m.endTime.View(),
 					),
+					lipgloss.JoinHorizontal(
+						lipgloss.Top,
+						noStyle.Render("Weekdays: "),
+						m.weekdays.View(),
+					),
+					lipgloss.JoinHorizontal(
+						lipgloss.Top,
+						noStyle.Render("Dates: "),
+						m.dates.View(),
+					),
+					lipgloss.JoinHorizontal(
+						lipgloss.Top,
+						noStyle.Render("Time Zone: "),
+						m.timeZone.View(),
+					),
+					lipgloss.JoinHorizontal(
+						lipgloss.Top,
+						noStyle.Render("Connections per Download: "),
+						m.numParts.View(),
+					),
+					lipgloss.JoinHorizontal(
+						lipgloss.Top,
+						noStyle.Render("Max Connections: "),
+						m.maxParts.View(),
+					),
+					lipgloss.JoinHorizontal(
+						lipgloss.Top,
+						noStyle.Render("Min Part Size: "),
+						m.minPartSize.View(),
+					),
+					lipgloss.JoinHorizontal(
+						lipgloss.Top,
+						noStyle.Render("Headers: "),
+						m.headers.View(),
+					),
+					lipgloss.JoinHorizontal(
+						lipgloss.Top,
+						noStyle.Render("Proxy: "),
+						m.proxy.View(),
+					),
+					lipgloss.JoinHorizontal(
+						lipgloss.Top,
+						noStyle.Render("Bandwidth Schedule: "),
+						m.bandwidthSchedule.View(),
+					),
 				),
 				lipgloss.JoinHorizontal(
 					lipgloss.Top,
@@ -413,14 +774,24 @@ func (m *AddQueueTab) resetForm() {
 	m.nameInput.SetValue("")
 	m.targetDirInput.SetValue("")
 	m.maxParallel.SetValue("")
+	m.retries.SetValue("")
 	m.speedLimit.SetValue("")
 	m.startTime.SetValue("")
 	m.endTime.SetValue("")
+	m.weekdays.SetValue("")
+	m.dates.SetValue("")
+	m.timeZone.SetValue("")
+	m.numParts.SetValue("")
+	m.maxParts.SetValue("")
+	m.minPartSize.SetValue("")
+	m.headers.SetValue("")
+	m.proxy.SetValue("")
+	m.bandwidthSchedule.SetValue("")
 	m.focusIndex = 0
 	m.footerMessage = ""
 }
 
-func makeQueueInfo(name, targetDir, maxParallel, speedLimit, startTime, endTime string) (models.QueueInfo, error) {
+func makeQueueInfo(name, targetDir, maxParallel, retries, speedLimit, startTime, endTime, numParts, maxParts, minPartSize, headers, proxy, bandwidthSchedule, weekdays, dates, timeZone string) (models.QueueInfo, error) {
 	if name == "" {
 		return models.QueueInfo{}, errors.New("name cannot be empty")
 	}
@@ -434,6 +805,13 @@ func makeQueueInfo(name, targetDir, maxParallel, speedLimit, startTime, endTime
 	if mp < 1 {
 		return models.QueueInfo{}, errors.New("max parallel downloads must be greater than 0")
 	}
+	nr := models.NUMBER_OF_RETRIES
+	if retries != "" {
+		nr, err = strconv.Atoi(retries)
+		if err != nil || nr < 0 {
+			return models.QueueInfo{}, errors.New("retries must be a number greater or equal to 0")
+		}
+	}
 	sp, err := strconv.ParseInt(speedLimit, 10, 64)
 	if err != nil {
 		return models.QueueInfo{}, errors.New("speed limit must be a number")
@@ -449,13 +827,60 @@ func makeQueueInfo(name, targetDir, maxParallel, speedLimit, startTime, endTime
 	if err != nil {
 		return models.QueueInfo{}, errors.New("invalid end time. Must be in the format HH:MM")
 	}
+	np := 0
+	if numParts != "" {
+		np, err = strconv.Atoi(numParts)
+		if err != nil || np < 1 {
+			return models.QueueInfo{}, errors.New("connections per download must be a number greater than 0")
+		}
+	}
+	xp := 0
+	if maxParts != "" {
+		xp, err = strconv.Atoi(maxParts)
+		if err != nil || xp < 1 {
+			return models.QueueInfo{}, errors.New("max connections must be a number greater than 0")
+		}
+	}
+	var mps int64
+	if minPartSize != "" {
+		mps, err = strconv.ParseInt(minPartSize, 10, 64)
+		if err != nil || mps < 1 {
+			return models.QueueInfo{}, errors.New("min part size must be a number greater than 0")
+		}
+	}
+	h, err := models.ParseHeaders(headers)
+	if err != nil {
+		return models.QueueInfo{}, err
+	}
+	bs, err := models.ParseBandwidthSchedule(bandwidthSchedule)
+	if err != nil {
+		return models.QueueInfo{}, err
+	}
+	wd, err := models.ParseWeekdays(weekdays)
+	if err != nil {
+		return models.QueueInfo{}, err
+	}
+	dr, err := models.ParseDateRanges(dates)
+	if err != nil {
+		return models.QueueInfo{}, err
+	}
 	return models.QueueInfo{
-		Name:            name,
-		TargetDirectory: targetDir,
-		MaxParallel:     mp,
-		SpeedLimit:      sp,
-		StartTime:       st,
-		EndTime:         et,
+		Name:              name,
+		TargetDirectory:   targetDir,
+		MaxParallel:       mp,
+		NumRetries:        nr,
+		SpeedLimit:        sp,
+		StartTime:         st,
+		EndTime:           et,
+		NumParts:          np,
+		MaxParts:          xp,
+		MinPartSize:       mps,
+		Headers:           h,
+		Proxy:             proxy,
+		BandwidthSchedule: bs,
+		Weekdays:          wd,
+		DateRanges:        dr,
+		TimeZone:          strings.TrimSpace(timeZone),
 	}, nil
 }
 
@@ -2,12 +2,15 @@ package tui
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Kafsh-e-Mardane-Varzeshi-Hypo-Test-Team/CT_HW1/internal/models"
 	"github.com/charmbracelet/bubbles/help"
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/table"
+	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 )
@@ -18,6 +21,7 @@ type downloadsKeyMap struct {
 	Delete     key.Binding
 	Pause      key.Binding
 	Retry      key.Binding
+	SpeedLimit key.Binding
 	Quit       key.Binding
 }
 
@@ -28,7 +32,7 @@ func (k downloadsKeyMap) ShortHelp() []key.Binding {
 func (k downloadsKeyMap) FullHelp() [][]key.Binding {
 	return [][]key.Binding{
 		{k.Navigation, k.Quit},
-		{k.Delete, k.Pause, k.Retry},
+		{k.Delete, k.Pause, k.Retry, k.SpeedLimit},
 	}
 }
 
@@ -39,14 +43,21 @@ type DownloadsTab struct {
 	help         help.Model
 	keys         downloadsKeyMap
 	footerString string
+	// speedLimit edits the speed limit of the download editingID while
+	// editingSpeed is set.
+	speedLimit   textinput.Model
+	editingSpeed bool
+	editingID    int
 }
 
 func NewDownloadsTab(manager *models.Manager) DownloadsTab {
 	columns := []table.Column{
 		{Title: "URL", Width: 30},
 		{Title: "Queue", Width: 20},
+		{Title: "Auth", Width: 12},
 		{Title: "Status", Width: 15},
 		{Title: "Transfer Rate", Width: 15},
+		{Title: "Speed Limit", Width: 15},
 		{Title: "Progress", Width: 10},
 	}
 
@@ -73,6 +84,12 @@ func NewDownloadsTab(manager *models.Manager) DownloadsTab {
 
 	t.KeyMap.HalfPageDown.SetEnabled(false)
 
+	speedLimit := textinput.New()
+	speedLimit.Placeholder = "Bytes per second (0 for no limit)"
+	speedLimit.PromptStyle = focusedStyle
+	speedLimit.TextStyle = focusedStyle
+	speedLimit.Cursor.Style = cursorStyle
+
 	help := help.New()
 	help.ShowAll = true
 	help.FullSeparator = " \t "
@@ -99,12 +116,17 @@ func NewDownloadsTab(manager *models.Manager) DownloadsTab {
 				key.WithKeys("r"),
 				key.WithHelp("r", "retry"),
 			),
+			SpeedLimit: key.NewBinding(
+				key.WithKeys("s"),
+				key.WithHelp("s", "speed limit"),
+			),
 			Quit: key.NewBinding(
 				key.WithKeys("ctrl+c", "esc"),
 				key.WithHelp("ctrl+c/esc", "quit"),
 			),
 		},
 		footerString: "",
+		speedLimit:   speedLimit,
 	}
 
 	downloadsTab.updateRows()
@@ -125,6 +147,9 @@ func (m DownloadsTab) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case updateMsg:
 		return m, tickUpdate()
 	case tea.KeyMsg:
+		if m.editingSpeed {
+			return m.updateSpeedLimit(msg)
+		}
 		switch {
 		case key.Matches(msg, m.keys.Navigation):
 		case key.Matches(msg, m.keys.Pause):
@@ -147,6 +172,16 @@ func (m DownloadsTab) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.updateRows()
 				}
 			}
+		case key.Matches(msg, m.keys.SpeedLimit):
+			if m.table.Cursor() >= 0 && m.table.Cursor() < len(m.downloads) {
+				dl := m.downloads[m.table.Cursor()]
+				m.editingSpeed = true
+				m.editingID = dl.ID
+				m.speedLimit.SetValue(strconv.FormatInt(dl.SpeedLimit, 10))
+				m.speedLimit.CursorEnd()
+				m.footerString = ""
+				return m, m.speedLimit.Focus()
+			}
 		case key.Matches(msg, m.keys.Delete):
 			if m.table.Cursor() >= 0 && m.table.Cursor() < len(m.downloads) {
 				dl := m.downloads[m.table.Cursor()]
@@ -169,21 +204,64 @@ func (m DownloadsTab) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, cmd
 }
 
+// updateSpeedLimit handles the keys while a speed limit is edited. It always
+// returns a command, so the main view does not act on the keys as well.
+func (m DownloadsTab) updateSpeedLimit(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	switch msg.String() {
+	case "enter":
+		speedLimit, err := parseSpeedLimit(m.speedLimit.Value())
+		if err == nil {
+			err = m.manager.SetDownloadSpeedLimit(m.editingID, speedLimit)
+		}
+		if err != nil {
+			m.footerString = "Error: " + err.Error()
+		} else {
+			m.footerString = ""
+		}
+		m.editingSpeed = false
+		m.speedLimit.Blur()
+		m.updateRows()
+	case "esc":
+		m.editingSpeed = false
+		m.speedLimit.Blur()
+	case "ctrl+c":
+		return m, tea.Quit
+	default:
+		m.speedLimit, cmd = m.speedLimit.Update(msg)
+	}
+
+	if cmd == nil {
+		cmd = textinput.Blink
+	}
+	return m, cmd
+}
+
 func (m DownloadsTab) View() string {
 	if len(m.downloads) == 0 {
 		m.keys.Delete.SetEnabled(false)
 		m.keys.Pause.SetEnabled(false)
 		m.keys.Retry.SetEnabled(false)
+		m.keys.SpeedLimit.SetEnabled(false)
 	} else {
 		m.keys.Delete.SetEnabled(true)
 		m.keys.Pause.SetEnabled(true)
 		m.keys.Retry.SetEnabled(true)
+		m.keys.SpeedLimit.SetEnabled(true)
 	}
 
 	row := m.table.Cursor()
+	footerString := m.footerString
 
 	if row >= 0 && row < len(m.downloads) {
 		status := m.downloads[row].Status
+		if status == models.Failed && m.downloads[row].FailureReason != "" {
+			footerString = "Failed: " + m.downloads[row].FailureReason
+		}
+		if status == models.Pending && footerString == "" {
+			footerString = timeWindowString(m.downloads[row])
+		}
 		// Update the help view
 		switch status {
 		case models.InProgress, models.Pending:
@@ -201,10 +279,18 @@ func (m DownloadsTab) View() string {
 		}
 	}
 
+	if m.editingSpeed {
+		footerString = lipgloss.JoinHorizontal(
+			lipgloss.Top,
+			noStyle.Render("Speed Limit: "),
+			m.speedLimit.View(),
+		)
+	}
+
 	return lipgloss.JoinVertical(
 		lipgloss.Left,
 		borderedStyle.Render(m.table.View()),
-		noStyle.Render(m.footerString),
+		noStyle.Render(footerString),
 		helpStyle.Render(m.help.View(m.keys)),
 	)
 }
@@ -227,6 +313,9 @@ func (m *DownloadsTab) updateRows() {
 			statusString = "Failed"
 		case models.Pending:
 			statusString = "Pending"
+			if time.Now().Before(download.NotBefore) {
+				statusString = "Waiting"
+			}
 		case models.Cancelled:
 			statusString = "Cancelled"
 		default:
@@ -237,17 +326,21 @@ func (m *DownloadsTab) updateRows() {
 			rows = append(rows, []string{
 				download.URL,
 				download.QueueName,
+				download.AuthKind,
 				statusString,
 				"",
+				speedLimitString(download.SpeedLimit),
 				"100%",
 			})
 		} else {
 			rows = append(rows, []string{
 				download.URL,
 				download.QueueName,
+				download.AuthKind,
 				statusString,
 				speedString(download.TransferRate),
-				fmt.Sprintf("%#6.2f%%", download.Progress),
+				speedLimitString(download.SpeedLimit),
+				progressString(download),
 			})
 
 		}
@@ -256,6 +349,23 @@ func (m *DownloadsTab) updateRows() {
 	m.table.SetRows(rows)
 }
 
+// progressString shows the received bytes instead of a percentage when the
+// size of the file is unknown.
+func progressString(download *models.DownloadInfo) string {
+	if download.TotalSize < 0 {
+		return sizeString(float64(download.DownloadedSize))
+	}
+	return fmt.Sprintf("%#6.2f%%", download.Progress)
+}
+
+// speedLimitString shows an unset speed limit as empty.
+func speedLimitString(speedLimit int64) string {
+	if speedLimit == 0 {
+		return ""
+	}
+	return speedString(float64(speedLimit))
+}
+
 func speedString(speed float64) string {
 	return fmt.Sprintf("%s/s", sizeString(speed))
 }
@@ -282,3 +392,16 @@ func tickUpdate() tea.Cmd {
 		return updateMsg{}
 	})
 }
+
+// timeWindowString tells when a download may start and until when, or ""
+// when it may start at any time.
+func timeWindowString(download *models.DownloadInfo) string {
+	var fields []string
+	if !download.NotBefore.IsZero() {
+		fields = append(fields, "Not before: "+download.NotBefore.Format("2006-01-02 15:04"))
+	}
+	if !download.Deadline.IsZero() {
+		fields = append(fields, "Deadline: "+download.Deadline.Format("2006-01-02 15:04"))
+	}
+	return strings.Join(fields, "  ")
+}
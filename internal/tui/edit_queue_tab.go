@@ -3,6 +3,7 @@ package tui
 import (
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/Kafsh-e-Mardane-Varzeshi-Hypo-Test-Team/CT_HW1/internal/models"
 	"github.com/charmbracelet/bubbles/help"
@@ -36,25 +37,47 @@ type EditQueueField int
 const (
 	editTargetDirectoryField EditQueueField = iota
 	editMaxParallelField
+	editRetriesField
 	editSpeedLimitField
 	editStartTimeField
 	editEndTimeField
+	editWeekdaysField
+	editDatesField
+	editTimeZoneField
+	editNumPartsField
+	editMaxPartsField
+	editMinPartSizeField
+	editHeadersField
+	editAuthField
+	editProxyField
+	editBandwidthScheduleField
 	editConfirmQueueField
 	editCancelQueueField
 )
 
 type EditQueueTab struct {
-	manager        *models.Manager
-	queueName      string
-	focusIndex     EditQueueField
-	targetDirInput textinput.Model
-	maxParallel    textinput.Model
-	speedLimit     textinput.Model
-	startTime      textinput.Model
-	endTime        textinput.Model
-	help           help.Model
-	keys           editQueueKeyMap
-	footerMessage  string
+	manager           *models.Manager
+	queueName         string
+	focusIndex        EditQueueField
+	targetDirInput    textinput.Model
+	maxParallel       textinput.Model
+	retries           textinput.Model
+	speedLimit        textinput.Model
+	startTime         textinput.Model
+	endTime           textinput.Model
+	weekdays          textinput.Model
+	dates             textinput.Model
+	timeZone          textinput.Model
+	numParts          textinput.Model
+	maxParts          textinput.Model
+	minPartSize       textinput.Model
+	headers           textinput.Model
+	auth              textinput.Model
+	proxy             textinput.Model
+	bandwidthSchedule textinput.Model
+	help              help.Model
+	keys              editQueueKeyMap
+	footerMessage     string
 }
 
 func NewEditQueueTab(manager *models.Manager, queueInfo *models.QueueInfo) EditQueueTab {
@@ -74,6 +97,13 @@ func NewEditQueueTab(manager *models.Manager, queueInfo *models.QueueInfo) EditQ
 	maxParallel.TextStyle = noStyle
 	maxParallel.Cursor.Style = cursorStyle
 
+	retries := textinput.New()
+	retries.Placeholder = "Enter retries of a failed request (empty for default)"
+	retries.SetValue(fmt.Sprint(queueInfo.NumRetries))
+	retries.PromptStyle = noStyle
+	retries.TextStyle = noStyle
+	retries.Cursor.Style = cursorStyle
+
 	speedLimit := textinput.New()
 	speedLimit.Placeholder = "Enter speed limit (Bytes per second) (0 for no limit)"
 	speedLimit.SetValue(fmt.Sprint(queueInfo.SpeedLimit))
@@ -95,20 +125,101 @@ func NewEditQueueTab(manager *models.Manager, queueInfo *models.QueueInfo) EditQ
 	endTime.TextStyle = noStyle
 	endTime.Cursor.Style = cursorStyle
 
+	weekdays := textinput.New()
+	weekdays.Placeholder = "Enter days to run on, e.g. Sat, Sun, Mon (empty with no dates for every day)"
+	weekdays.SetValue(models.FormatWeekdays(queueInfo.Weekdays))
+	weekdays.PromptStyle = noStyle
+	weekdays.TextStyle = noStyle
+	weekdays.Cursor.Style = cursorStyle
+
+	dates := textinput.New()
+	dates.Placeholder = "Enter dates to run on besides the days, e.g. 2026-03-20..2026-04-02, 2026-05-01"
+	dates.SetValue(models.FormatDateRanges(queueInfo.DateRanges))
+	dates.PromptStyle = noStyle
+	dates.TextStyle = noStyle
+	dates.Cursor.Style = cursorStyle
+
+	timeZone := textinput.New()
+	timeZone.Placeholder = "Enter time zone of the times, e.g. Asia/Tehran (empty for local)"
+	timeZone.SetValue(queueInfo.TimeZone)
+	timeZone.PromptStyle = noStyle
+	timeZone.TextStyle = noStyle
+	timeZone.Cursor.Style = cursorStyle
+
+	numParts := textinput.New()
+	numParts.Placeholder = "Enter connections per download (empty for default)"
+	numParts.SetValue(fmt.Sprint(queueInfo.NumParts))
+	numParts.PromptStyle = noStyle
+	numParts.TextStyle = noStyle
+	numParts.Cursor.Style = cursorStyle
+
+	maxParts := textinput.New()
+	maxParts.Placeholder = "Enter max connections to grow to while speed rises (empty to disable)"
+	maxParts.SetValue(maxPartsString(queueInfo.MaxParts))
+	maxParts.PromptStyle = noStyle
+	maxParts.TextStyle = noStyle
+	maxParts.Cursor.Style = cursorStyle
+
+	minPartSize := textinput.New()
+	minPartSize.Placeholder = "Enter minimum part size (Bytes) (empty for default)"
+	minPartSize.SetValue(fmt.Sprint(queueInfo.MinPartSize))
+	minPartSize.PromptStyle = noStyle
+	minPartSize.TextStyle = noStyle
+	minPartSize.Cursor.Style = cursorStyle
+
+	headers := textinput.New()
+	headers.Placeholder = "Enter default headers, Name: value | Name2: value (credential headers replace the stored ones)"
+	headers.SetValue(models.FormatHeaders(queueInfo.Headers))
+	headers.PromptStyle = noStyle
+	headers.TextStyle = noStyle
+	headers.Cursor.Style = cursorStyle
+
+	auth := textinput.New()
+	auth.Placeholder = "Kind of the stored credentials (clear to remove them)"
+	auth.SetValue(queueInfo.AuthKind)
+	auth.PromptStyle = noStyle
+	auth.TextStyle = noStyle
+	auth.Cursor.Style = cursorStyle
+
+	proxy := textinput.New()
+	proxy.Placeholder = "Enter proxy URL, or direct to bypass the global proxy (empty for global)"
+	proxy.SetValue(queueInfo.Proxy)
+	proxy.PromptStyle = noStyle
+	proxy.TextStyle = noStyle
+	proxy.Cursor.Style = cursorStyle
+
+	bandwidthSchedule := textinput.New()
+	bandwidthSchedule.Placeholder = "Enter speed limits by time of day, e.g. 09:00-17:00=204800, 22:00-06:00=0 (empty for none)"
+	bandwidthSchedule.SetValue(models.FormatBandwidthSchedule(queueInfo.BandwidthSchedule))
+	bandwidthSchedule.PromptStyle = noStyle
+	bandwidthSchedule.TextStyle = noStyle
+	bandwidthSchedule.Cursor.Style = cursorStyle
+
 	help := help.New()
 	help.ShowAll = true
 	help.FullSeparator = " \t "
 
 	return EditQueueTab{
-		manager:        manager,
-		focusIndex:     editTargetDirectoryField,
-		queueName:      name,
-		targetDirInput: targetDirInput,
-		maxParallel:    maxParallel,
-		speedLimit:     speedLimit,
-		startTime:      startTime,
-		endTime:        endTime,
-		help:           help,
+		manager:           manager,
+		focusIndex:        editTargetDirectoryField,
+		queueName:         name,
+		targetDirInput:    targetDirInput,
+		maxParallel:       maxParallel,
+		retries:           retries,
+		speedLimit:        speedLimit,
+		startTime:         startTime,
+		endTime:           endTime,
+		weekdays:          weekdays,
+		dates:             dates,
+		timeZone:          timeZone,
+		numParts:          numParts,
+		maxParts:          maxParts,
+		minPartSize:       minPartSize,
+		headers:           headers,
+		auth:              auth,
+		proxy:             proxy,
+		bandwidthSchedule: bandwidthSchedule,
+		help:              help,
 		keys: editQueueKeyMap{
 			Next:       key.NewBinding(key.WithKeys("tab"), key.WithHelp("tab", "next field")),
 			Prev:       key.NewBinding(key.WithKeys("shift+tab"), key.WithHelp("shift+tab", "previous field")),
@@ -132,7 +243,7 @@ func (m EditQueueTab) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case tea.KeyMsg:
 			switch msg.String() {
 			case "tab", "down":
-				m.focusIndex = min(m.focusIndex+1, 7)
+				m.focusIndex = min(m.focusIndex+1, editCancelQueueField)
 			case "up", "shift+tab":
 				m.focusIndex = max(m.focusIndex-1, 0)
 			case "ctrl+c", "esc":
@@ -146,7 +257,7 @@ func (m EditQueueTab) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case tea.KeyMsg:
 			switch msg.String() {
 			case "tab", "down":
-				m.focusIndex = min(m.focusIndex+1, 7)
+				m.focusIndex = min(m.focusIndex+1, editCancelQueueField)
 			case "up", "shift+tab":
 				m.focusIndex = max(m.focusIndex-1, 0)
 			case "ctrl+c", "esc":
@@ -155,12 +266,26 @@ func (m EditQueueTab) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		}
 		m.maxParallel, cmd = m.maxParallel.Update(msg)
+	case editRetriesField:
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			switch msg.String() {
+			case "tab", "down":
+				m.focusIndex = min(m.focusIndex+1, editCancelQueueField)
+			case "up", "shift+tab":
+				m.focusIndex = max(m.focusIndex-1, 0)
+			case "ctrl+c", "esc":
+				m.resetForm()
+				return m, func() tea.Msg { return CloseChildMsg{} }
+			}
+		}
+		m.retries, cmd = m.retries.Update(msg)
 	case editSpeedLimitField:
 		switch msg := msg.(type) {
 		case tea.KeyMsg:
 			switch msg.String() {
 			case "tab", "down":
-				m.focusIndex = min(m.focusIndex+1, 7)
+				m.focusIndex = min(m.focusIndex+1, editCancelQueueField)
 			case "up", "shift+tab":
 				m.focusIndex = max(m.focusIndex-1, 0)
 			case "ctrl+c", "esc":
@@ -174,7 +299,7 @@ func (m EditQueueTab) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case tea.KeyMsg:
 			switch msg.String() {
 			case "tab", "down":
-				m.focusIndex = min(m.focusIndex+1, 7)
+				m.focusIndex = min(m.focusIndex+1, editCancelQueueField)
 			case "up", "shift+tab":
 				m.focusIndex = max(m.focusIndex-1, 0)
 			case "ctrl+c", "esc":
@@ -188,7 +313,7 @@ func (m EditQueueTab) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case tea.KeyMsg:
 			switch msg.String() {
 			case "tab", "down":
-				m.focusIndex = min(m.focusIndex+1, 7)
+				m.focusIndex = min(m.focusIndex+1, editCancelQueueField)
 			case "up", "shift+tab":
 				m.focusIndex = max(m.focusIndex-1, 0)
 			case "ctrl+c", "esc":
@@ -197,6 +322,146 @@ func (m EditQueueTab) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		}
 		m.endTime, cmd = m.endTime.Update(msg)
+	case editWeekdaysField:
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			switch msg.String() {
+			case "tab", "down":
+				m.focusIndex = min(m.focusIndex+1, editCancelQueueField)
+			case "up", "shift+tab":
+				m.focusIndex = max(m.focusIndex-1, 0)
+			case "ctrl+c", "esc":
+				m.resetForm()
+				return m, func() tea.Msg { return CloseChildMsg{} }
+			}
+		}
+		m.weekdays, cmd = m.weekdays.Update(msg)
+	case editDatesField:
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			switch msg.String() {
+			case "tab", "down":
+				m.focusIndex = min(m.focusIndex+1, editCancelQueueField)
+			case "up", "shift+tab":
+				m.focusIndex = max(m.focusIndex-1, 0)
+			case "ctrl+c", "esc":
+				m.resetForm()
+				return m, func() tea.Msg { return CloseChildMsg{} }
+			}
+		}
+		m.dates, cmd = m.dates.Update(msg)
+	case editTimeZoneField:
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			switch msg.String() {
+			case "tab", "down":
+				m.focusIndex = min(m.focusIndex+1, editCancelQueueField)
+			case "up", "shift+tab":
+				m.focusIndex = max(m.focusIndex-1, 0)
+			case "ctrl+c", "esc":
+				m.resetForm()
+				return m, func() tea.Msg { return CloseChildMsg{} }
+			}
+		}
+		m.timeZone, cmd = m.timeZone.Update(msg)
+	case editNumPartsField:
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			switch msg.String() {
+			case "tab", "down":
+				m.focusIndex = min(m.focusIndex+1, editCancelQueueField)
+			case "up", "shift+tab":
+				m.focusIndex = max(m.focusIndex-1, 0)
+			case "ctrl+c", "esc":
+				m.resetForm()
+				return m, func() tea.Msg { return CloseChildMsg{} }
+			}
+		}
+		m.numParts, cmd = m.numParts.Update(msg)
+	case editMaxPartsField:
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			switch msg.String() {
+			case "tab", "down":
+				m.focusIndex = min(m.focusIndex+1, editCancelQueueField)
+			case "up", "shift+tab":
+				m.focusIndex = max(m.focusIndex-1, 0)
+			case "ctrl+c", "esc":
+				m.resetForm()
+				return m, func() tea.Msg { return CloseChildMsg{} }
+			}
+		}
+		m.maxParts, cmd = m.maxParts.Update(msg)
+	case editMinPartSizeField:
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			switch msg.String() {
+			case "tab", "down":
+				m.focusIndex = min(m.focusIndex+1, editCancelQueueField)
+			case "up", "shift+tab":
+				m.focusIndex = max(m.focusIndex-1, 0)
+			case "ctrl+c", "esc":
+				m.resetForm()
+				return m, func() tea.Msg { return CloseChildMsg{} }
+			}
+		}
+		m.minPartSize, cmd = m.minPartSize.Update(msg)
+	case editHeadersField:
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			switch msg.String() {
+			case "tab", "down":
+				m.focusIndex = min(m.focusIndex+1, editCancelQueueField)
+			case "up", "shift+tab":
+				m.focusIndex = max(m.focusIndex-1, 0)
+			case "ctrl+c", "esc":
+				m.resetForm()
+				return m, func() tea.Msg { return CloseChildMsg{} }
+			}
+		}
+		m.headers, cmd = m.headers.Update(msg)
+	case editAuthField:
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			switch msg.String() {
+			case "tab", "down":
+				m.focusIndex = min(m.focusIndex+1, editCancelQueueField)
+			case "up", "shift+tab":
+				m.focusIndex = max(m.focusIndex-1, 0)
+			case "ctrl+c", "esc":
+				m.resetForm()
+				return m, func() tea.Msg { return CloseChildMsg{} }
+			}
+		}
+		m.auth, cmd = m.auth.Update(msg)
+	case editProxyField:
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			switch msg.String() {
+			case "tab", "down":
+				m.focusIndex = min(m.focusIndex+1, editCancelQueueField)
+			case "up", "shift+tab":
+				m.focusIndex = max(m.focusIndex-1, 0)
+			case "ctrl+c", "esc":
+				m.resetForm()
+				return m, func() tea.Msg { return CloseChildMsg{} }
+			}
+		}
+		m.proxy, cmd = m.proxy.Update(msg)
+	case editBandwidthScheduleField:
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			switch msg.String() {
+			case "tab", "down":
+				m.focusIndex = min(m.focusIndex+1, editCancelQueueField)
+			case "up", "shift+tab":
+				m.focusIndex = max(m.focusIndex-1, 0)
+			case "ctrl+c", "esc":
+				m.resetForm()
+				return m, func() tea.Msg { return CloseChildMsg{} }
+			}
+		}
+		m.bandwidthSchedule, cmd = m.bandwidthSchedule.Update(msg)
 	case editConfirmQueueField:
 		switch msg := msg.(type) {
 		case tea.KeyMsg:
@@ -205,16 +470,27 @@ func (m EditQueueTab) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				name := m.queueName
 				targetDir := m.targetDirInput.Value()
 				maxParallel := m.maxParallel.Value()
+				retries := m.retries.Value()
 				speedLimit := m.speedLimit.Value()
 				startTime := m.startTime.Value()
 				endTime := m.endTime.Value()
-
-				queueInfo, err := makeQueueInfo(name, targetDir, maxParallel, speedLimit, startTime, endTime)
+				numParts := m.numParts.Value()
+				maxParts := m.maxParts.Value()
+				minPartSize := m.minPartSize.Value()
+				headers := m.headers.Value()
+				proxy := m.proxy.Value()
+				bandwidthSchedule := m.bandwidthSchedule.Value()
+				weekdays := m.weekdays.Value()
+				dates := m.dates.Value()
+				timeZone := m.timeZone.Value()
+
+				queueInfo, err := makeQueueInfo(name, targetDir, maxParallel, retries, speedLimit, startTime, endTime, numParts, maxParts, minPartSize, headers, proxy, bandwidthSchedule, weekdays, dates, timeZone)
 
 				if err != nil {
 					m.footerMessage = err.Error()
 					return m, nil
 				}
+				queueInfo.AuthKind = strings.TrimSpace(m.auth.Value())
 
 				err = m.manager.UpdateQueue(queueInfo)
 				if err != nil {
@@ -243,7 +519,7 @@ func (m EditQueueTab) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.resetForm()
 				return m, func() tea.Msg { return CloseChildMsg{} }
 			case "up":
-				m.focusIndex = editEndTimeField
+				m.focusIndex = editBandwidthScheduleField
 			case "left", "shift+tab":
 				m.focusIndex = editConfirmQueueField
 				cmd = tea.Cmd(textinput.Blink)
@@ -261,20 +537,53 @@ func (m EditQueueTab) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 func (m *EditQueueTab) updateFocus() {
 	m.targetDirInput.Blur()
 	m.maxParallel.Blur()
+	m.retries.Blur()
 	m.speedLimit.Blur()
 	m.startTime.Blur()
 	m.endTime.Blur()
+	m.weekdays.Blur()
+	m.dates.Blur()
+	m.timeZone.Blur()
+	m.numParts.Blur()
+	m.maxParts.Blur()
+	m.minPartSize.Blur()
+	m.headers.Blur()
+	m.auth.Blur()
+	m.proxy.Blur()
+	m.bandwidthSchedule.Blur()
 
 	m.targetDirInput.PromptStyle = noStyle
 	m.targetDirInput.TextStyle = noStyle
 	m.maxParallel.PromptStyle = noStyle
 	m.maxParallel.TextStyle = noStyle
+	m.retries.PromptStyle = noStyle
+	m.retries.TextStyle = noStyle
 	m.speedLimit.PromptStyle = noStyle
 	m.speedLimit.TextStyle = noStyle
 	m.startTime.PromptStyle = noStyle
 	m.startTime.TextStyle = noStyle
 	m.endTime.PromptStyle = noStyle
 	m.endTime.TextStyle = noStyle
+	m.weekdays.PromptStyle = noStyle
+	m.weekdays.TextStyle = noStyle
+	m.dates.PromptStyle = noStyle
+	m.dates.TextStyle = noStyle
+	m.timeZone.PromptStyle = noStyle
+	m.timeZone.TextStyle = noStyle
+	m.numParts.PromptStyle = noStyle
+	m.numParts.TextStyle = noStyle
+	m.maxParts.PromptStyle = noStyle
+	m.maxParts.TextStyle = noStyle
+	m.minPartSize.PromptStyle = noStyle
+	m.minPartSize.TextStyle = noStyle
+	m.headers.PromptStyle = noStyle
+	m.headers.TextStyle = noStyle
+	m.auth.PromptStyle = noStyle
+	m.auth.TextStyle = noStyle
+	m.proxy.PromptStyle = noStyle
+	m.proxy.TextStyle = noStyle
+	m.bandwidthSchedule.PromptStyle = noStyle
+	m.bandwidthSchedule.TextStyle = noStyle
 
 	switch m.focusIndex {
 	case editTargetDirectoryField:
@@ -285,6 +594,10 @@ func (m *EditQueueTab) updateFocus() {
 		m.maxParallel.Focus()
 		m.maxParallel.PromptStyle = focusedStyle
 		m.maxParallel.TextStyle = focusedStyle
+	case editRetriesField:
+		m.retries.Focus()
+		m.retries.PromptStyle = focusedStyle
+		m.retries.TextStyle = focusedStyle
 	case editSpeedLimitField:
 		m.speedLimit.Focus()
 		m.speedLimit.PromptStyle = focusedStyle
@@ -297,6 +610,46 @@ func (m *EditQueueTab) updateFocus() {
 		m.endTime.Focus()
 		m.endTime.PromptStyle = focusedStyle
 		m.endTime.TextStyle = focusedStyle
+	case editWeekdaysField:
+		m.weekdays.Focus()
+		m.weekdays.PromptStyle = focusedStyle
+		m.weekdays.TextStyle = focusedStyle
+	case editDatesField:
+		m.dates.Focus()
+		m.dates.PromptStyle = focusedStyle
+		m.dates.TextStyle = focusedStyle
+	case editTimeZoneField:
+		m.timeZone.Focus()
+		m.timeZone.PromptStyle = focusedStyle
+		m.timeZone.TextStyle = focusedStyle
+	case editNumPartsField:
+		m.numParts.Focus()
+		m.numParts.PromptStyle = focusedStyle
+		m.numParts.TextStyle = focusedStyle
+	case editMaxPartsField:
+		m.maxParts.Focus()
+		m.maxParts.PromptStyle = focusedStyle
+		m.maxParts.TextStyle = focusedStyle
+	case editMinPartSizeField:
+		m.minPartSize.Focus()
+		m.minPartSize.PromptStyle = focusedStyle
+		m.minPartSize.TextStyle = focusedStyle
+	case editHeadersField:
+		m.headers.Focus()
+		m.headers.PromptStyle = focusedStyle
+		m.headers.TextStyle = focusedStyle
+	case editAuthField:
+		m.auth.Focus()
+		m.auth.PromptStyle = focusedStyle
+		m.auth.TextStyle = focusedStyle
+	case editProxyField:
+		m.proxy.Focus()
+		m.proxy.PromptStyle = focusedStyle
+		m.proxy.TextStyle = focusedStyle
+	case editBandwidthScheduleField:
+		m.bandwidthSchedule.Focus()
+		m.bandwidthSchedule.PromptStyle = focusedStyle
+		m.bandwidthSchedule.TextStyle = focusedStyle
 	}
 }
 
@@ -351,6 +704,11 @@ func (m EditQueueTab) View() string {
 						noStyle.Render("Max Parallel Downloads: "),
 						m.maxParallel.View(),
 					),
+					lipgloss.JoinHorizontal(
+						lipgloss.Top,
+						noStyle.Render("Retries: "),
+						m.retries.View(),
+					),
 					lipgloss.JoinHorizontal(
 						lipgloss.Top,
 						noStyle.Render("Speed Limit: "),
@@ -366,6 +724,56 @@ func (m EditQueueTab) View() string {
 						noStyle.Render("End Time: "),
 						m.endTime.View(),
 					),
+					lipgloss.JoinHorizontal(
+						lipgloss.Top,
+						noStyle.Render("Weekdays: "),
+						m.weekdays.View(),
+					),
+					lipgloss.JoinHorizontal(
+						lipgloss.Top,
+						noStyle.Render("Dates: "),
+						m.dates.View(),
+					),
+					lipgloss.JoinHorizontal(
+						lipgloss.Top,
+						noStyle.Render("Time Zone: "),
+						m.timeZone.View(),
+					),
+					lipgloss.JoinHorizontal(
+						lipgloss.Top,
+						noStyle.Render("Connections per Download: "),
+						m.numParts.View(),
+					),
+					lipgloss.JoinHorizontal(
+						lipgloss.Top,
+						noStyle.Render("Max Connections: "),
+						m.maxParts.View(),
+					),
+					lipgloss.JoinHorizontal(
+						lipgloss.Top,
+						noStyle.Render("Min Part Size: "),
+						m.minPartSize.View(),
+					),
+					lipgloss.JoinHorizontal(
+						lipgloss.Top,
+						noStyle.Render("Headers: "),
+						m.headers.View(),
+					),
+					lipgloss.JoinHorizontal(
+						lipgloss.Top,
+						noStyle.Render("Stored Credentials: "),
+						m.auth.View(),
+					),
+					lipgloss.JoinHorizontal(
+						lipgloss.Top,
+						noStyle.Render("Proxy: "),
+						m.proxy.View(),
+					),
+					lipgloss.JoinHorizontal(
+						lipgloss.Top,
+						noStyle.Render("Bandwidth Schedule: "),
+						m.bandwidthSchedule.View(),
+					),
 				),
 				lipgloss.JoinHorizontal(
 					lipgloss.Top,
@@ -382,12 +790,30 @@ func (m EditQueueTab) View() string {
 	return docStyle.Render(form)
 }
 
+func maxPartsString(maxParts int) string {
+	if maxParts == 0 {
+		return ""
+	}
+	return fmt.Sprint(maxParts)
+}
+
 func (m *EditQueueTab) resetForm() {
 	m.targetDirInput.SetValue("")
 	m.maxParallel.SetValue("")
+	m.retries.SetValue("")
 	m.speedLimit.SetValue("")
 	m.startTime.SetValue("")
 	m.endTime.SetValue("")
+	m.weekdays.SetValue("")
+	m.dates.SetValue("")
+	m.timeZone.SetValue("")
+	m.numParts.SetValue("")
+	m.maxParts.SetValue("")
+	m.minPartSize.SetValue("")
+	m.headers.SetValue("")
+	m.auth.SetValue("")
+	m.proxy.SetValue("")
+	m.bandwidthSchedule.SetValue("")
 	m.focusIndex = 0
 	m.footerMessage = ""
 }
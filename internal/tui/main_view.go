@@ -12,6 +12,7 @@ const (
 	addDownload tab = iota
 	downloads
 	queues
+	settings
 )
 
 type MainView struct {
@@ -20,6 +21,7 @@ type MainView struct {
 	downloadTab    tea.Model
 	queueTab       tea.Model
 	addDownloadTab tea.Model
+	settingsTab    tea.Model
 	footerString   string
 }
 
@@ -30,6 +32,7 @@ func NewMainView(manager *models.Manager) MainView {
 		downloadTab:    NewDownloadsTab(manager),
 		queueTab:       NewQueuesTab(manager),
 		addDownloadTab: NewAddDownloadTab(manager),
+		settingsTab:    NewSettingsTab(manager),
 		footerString:   "",
 	}
 }
@@ -78,6 +81,23 @@ func (m MainView) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			case "left":
 				m.currentTab = downloads
 				m.downloadTab, cmd = m.downloadTab.Update(nil)
+			case "right":
+				m.currentTab = settings
+				m.settingsTab, cmd = m.settingsTab.Update(nil)
+			case "esc", "ctrl+c":
+				return m, tea.Quit
+			}
+		}
+	case settings:
+		m.settingsTab, cmd = m.settingsTab.Update(msg)
+		if cmd != nil {
+			return m, cmd
+		}
+		if msg, ok := msg.(tea.KeyMsg); ok {
+			switch msg.String() {
+			case "left":
+				m.currentTab = queues
+				m.queueTab, cmd = m.queueTab.Update(nil)
 			case "right":
 			case "esc", "ctrl+c":
 				return m, tea.Quit
@@ -105,7 +125,7 @@ func (m MainView) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 func (m MainView) View() string {
 	// Render tabs
-	tabs := []string{"Add Download", "Downloads List", "Queues List"}
+	tabs := []string{"Add Download", "Downloads List", "Queues List", "Settings"}
 	var renderedTabs []string
 
 	for i, t := range tabs {
@@ -126,6 +146,8 @@ func (m MainView) View() string {
 		content = m.queueTab.View()
 	case addDownload:
 		content = m.addDownloadTab.View()
+	case settings:
+		content = m.settingsTab.View()
 	}
 	return docStyle.Render(lipgloss.JoinVertical(
 		lipgloss.Left,
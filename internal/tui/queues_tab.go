@@ -17,6 +17,11 @@ type queuesKeyMap struct {
 	Delete     key.Binding
 	Edit       key.Binding
 	NewQueue   key.Binding
+	Start      key.Binding
+	Stop       key.Binding
+	StartUntil key.Binding
+	StopUntil  key.Binding
+	Schedule   key.Binding
 	Quit       key.Binding
 }
 
@@ -28,6 +33,7 @@ func (k queuesKeyMap) FullHelp() [][]key.Binding {
 	return [][]key.Binding{
 		{k.Navigation, k.Quit},
 		{k.NewQueue, k.Edit, k.Delete},
+		{k.Start, k.Stop, k.StartUntil, k.StopUntil, k.Schedule},
 	}
 }
 
@@ -50,8 +56,10 @@ func NewQueuesTab(manager *models.Manager) QueuesTab {
 		{Title: "Target Directory", Width: 30},
 		{Title: "Max Parallel", Width: 15},
 		{Title: "Speed Limit", Width: 15},
+		{Title: "Connections", Width: 12},
 		{Title: "Start Time", Width: 10},
 		{Title: "End Time", Width: 10},
+		{Title: "State", Width: 20},
 	}
 	rows := []table.Row{}
 
@@ -106,6 +114,26 @@ func NewQueuesTab(manager *models.Manager) QueuesTab {
 				key.WithKeys("e"),
 				key.WithHelp("e", "edit"),
 			),
+			Start: key.NewBinding(
+				key.WithKeys("s"),
+				key.WithHelp("s", "start"),
+			),
+			Stop: key.NewBinding(
+				key.WithKeys("x"),
+				key.WithHelp("x", "stop"),
+			),
+			StartUntil: key.NewBinding(
+				key.WithKeys("S"),
+				key.WithHelp("S", "start until next scheduled stop"),
+			),
+			StopUntil: key.NewBinding(
+				key.WithKeys("X"),
+				key.WithHelp("X", "stop until next scheduled start"),
+			),
+			Schedule: key.NewBinding(
+				key.WithKeys("a"),
+				key.WithHelp("a", "follow schedule"),
+			),
 			Quit: key.NewBinding(
 				key.WithKeys("ctrl+c", "esc", "q"),
 				key.WithHelp("ctrl+c/esc", "quit"),
@@ -170,6 +198,16 @@ func (m QueuesTab) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.editQueueTab = NewEditQueueTab(m.manager, q)
 					cmd = m.editQueueTab.Init()
 				}
+			case key.Matches(msg, m.keys.Start):
+				m.setOverride(models.OVERRIDE_START, false)
+			case key.Matches(msg, m.keys.Stop):
+				m.setOverride(models.OVERRIDE_STOP, false)
+			case key.Matches(msg, m.keys.StartUntil):
+				m.setOverride(models.OVERRIDE_START, true)
+			case key.Matches(msg, m.keys.StopUntil):
+				m.setOverride(models.OVERRIDE_STOP, true)
+			case key.Matches(msg, m.keys.Schedule):
+				m.setOverride(models.OVERRIDE_NONE, false)
 			case key.Matches(msg, m.keys.Quit):
 				return m, tea.Quit
 			}
@@ -197,9 +235,19 @@ func (m QueuesTab) View() string {
 		if len(m.queues) == 0 {
 			m.keys.Delete.SetEnabled(false)
 			m.keys.Edit.SetEnabled(false)
+			m.keys.Start.SetEnabled(false)
+			m.keys.Stop.SetEnabled(false)
+			m.keys.StartUntil.SetEnabled(false)
+			m.keys.StopUntil.SetEnabled(false)
+			m.keys.Schedule.SetEnabled(false)
 		} else {
 			m.keys.Delete.SetEnabled(true)
 			m.keys.Edit.SetEnabled(true)
+			m.keys.Start.SetEnabled(true)
+			m.keys.Stop.SetEnabled(true)
+			m.keys.StartUntil.SetEnabled(true)
+			m.keys.StopUntil.SetEnabled(true)
+			m.keys.Schedule.SetEnabled(true)
 		}
 
 		return lipgloss.JoinVertical(
@@ -221,15 +269,52 @@ func (m *QueuesTab) updateRows() {
 		} else {
 			sp = speedString(float64(queue.SpeedLimit))
 		}
+		connections := fmt.Sprintf("%d", queue.NumParts)
+		if queue.MaxParts > queue.NumParts {
+			connections = fmt.Sprintf("%d-%d", queue.NumParts, queue.MaxParts)
+		}
 		rows = append(rows, []string{
 			queue.Name,
 			queue.TargetDirectory,
 			fmt.Sprintf("%d", queue.MaxParallel),
 			sp,
+			connections,
 			queue.StartTime.Format("15:04"),
 			queue.EndTime.Format("15:04"),
+			stateString(queue),
 		})
 	}
 
 	m.table.SetRows(rows)
 }
+
+// setOverride starts or stops the selected queue by hand, or returns it to
+// its schedule.
+func (m *QueuesTab) setOverride(override string, untilTransition bool) {
+	if m.table.Cursor() < 0 || m.table.Cursor() >= len(m.queues) {
+		return
+	}
+	err := m.manager.SetQueueOverride(m.queues[m.table.Cursor()].Name, override, untilTransition)
+	if err != nil {
+		m.footerString = "Error: " + err.Error()
+	} else {
+		m.footerString = ""
+	}
+	m.updateRows()
+}
+
+// stateString shows whether the queue is running and what keeps it so.
+func stateString(queue *models.QueueInfo) string {
+	state := "Stopped"
+	if queue.Active {
+		state = "Running"
+	}
+	switch {
+	case queue.Override == models.OVERRIDE_NONE:
+		return state
+	case queue.OverrideUntil:
+		return state + " (for now)"
+	default:
+		return state + " (manual)"
+	}
+}